@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"cse224/proj3/pkg/gohttp"
 )
@@ -14,6 +26,30 @@ func main() {
 	var useDefault = flag.Bool("use_default", false, "whether to use the Golang standard library HTTP server")
 	var port = flag.Int("port", 8080, "the localhost port to listen on")
 	var docRoot = flag.String("doc_root", "htdocs", "path to the doc root directory")
+	var vhosts = flag.String("vhosts", "", "comma-separated host=doc_root pairs serving each host, e.g. \"example.com=sites/a,*.example.org=sites/b\", with -doc_root for other hosts (GoHTTP server only)")
+	var redirects = flag.String("redirects", "", "path to a redirect table, with a \"code from to\" rule per line, e.g. \"301 /old/ /new/\" (GoHTTP server only)")
+	var autoindex = flag.Bool("autoindex", false, "whether to list directories without an index.html (GoHTTP server only)")
+	var symlinks = flag.String("symlinks", "follow", "how to treat symbolic links under the doc root: follow, owner (follow if owned by the link's owner) or deny (GoHTTP server only)")
+	var hide = flag.String("hide", "", "comma-separated file name patterns never to serve, e.g. \"*.bak,*.orig\" (GoHTTP server only)")
+	var writable = flag.String("writable", "", "comma-separated directories, as URL paths, e.g. \"/artifacts\", whose files can be uploaded with PUT and removed with DELETE (GoHTTP server only)")
+	var maxUploadBytes = flag.Int64("max_upload_bytes", 32<<20, "the largest PUT request body in bytes (GoHTTP server only)")
+	var uploadPath = flag.String("upload_path", "", "a path, e.g. /uploads, where files POSTed with a multipart/form-data form are saved into the same directory under the doc root (GoHTTP server only)")
+	var compress = flag.Bool("compress", false, "whether to compress responses on the fly (GoHTTP server only)")
+	var compressMinSize = flag.Int64("compress_min_size", 1024, "the smallest response size in bytes worth compressing (GoHTTP server only)")
+	var tlsCert = flag.String("tls_cert", "", "comma-separated paths to PEM certificate files, one per hostname, to serve over TLS (GoHTTP server only)")
+	var tlsKey = flag.String("tls_key", "", "comma-separated paths to the PEM private key files of -tls_cert, in the same order")
+	var shutdownTimeout = flag.Duration("shutdown_timeout", 10*time.Second, "how long to let requests in flight finish on SIGTERM or SIGINT (GoHTTP server only)")
+	var readHeaderTimeout = flag.Duration("read_header_timeout", 5*time.Second, "how long clients get to send the request line and headers (GoHTTP server only)")
+	var readTimeout = flag.Duration("read_timeout", 30*time.Second, "how long clients get to send a request body, 0 for no limit (GoHTTP server only)")
+	var writeTimeout = flag.Duration("write_timeout", 30*time.Second, "how long the server gets to send a response, 0 for no limit (GoHTTP server only)")
+	var idleTimeout = flag.Duration("idle_timeout", 5*time.Second, "how long to keep an idle keep-alive connection open (GoHTTP server only)")
+	var maxHeaderBytes = flag.Int("max_header_bytes", 1<<20, "the largest request line and headers in bytes (GoHTTP server only)")
+	var maxURIBytes = flag.Int("max_uri_bytes", 8<<10, "the longest request URL in bytes (GoHTTP server only)")
+	var accessLog = flag.String("access_log", "", "path to the access log file, \"-\" for stdout, reopened on SIGUSR1 (GoHTTP server only)")
+	var accessLogFormat = flag.String("access_log_format", "combined", "the access log format: common, combined or json")
+	var logLevel = flag.String("log_level", "info", "the least severe server messages to log: debug, info, warn or error (GoHTTP server only)")
+	var metricsPath = flag.String("metrics_path", "", "the path to serve Prometheus metrics at, e.g. /metrics (GoHTTP server only)")
+	var metricsAddr = flag.String("metrics_addr", "", "a separate address, e.g. :9090, to serve Prometheus metrics at /metrics (GoHTTP server only)")
 	flag.Parse()
 
 	// Log server configs
@@ -21,6 +57,29 @@ func main() {
 	log.Printf("  use_default: %v", *useDefault)
 	log.Printf("  port: %v", *port)
 	log.Printf("  doc_root: %v", *docRoot)
+	log.Printf("  vhosts: %v", *vhosts)
+	log.Printf("  redirects: %v", *redirects)
+	log.Printf("  autoindex: %v", *autoindex)
+	log.Printf("  symlinks: %v", *symlinks)
+	log.Printf("  hide: %v", *hide)
+	log.Printf("  writable: %v", *writable)
+	log.Printf("  max_upload_bytes: %v", *maxUploadBytes)
+	log.Printf("  upload_path: %v", *uploadPath)
+	log.Printf("  compress: %v", *compress)
+	log.Printf("  tls_cert: %v", *tlsCert)
+	log.Printf("  tls_key: %v", *tlsKey)
+	log.Printf("  shutdown_timeout: %v", *shutdownTimeout)
+	log.Printf("  read_header_timeout: %v", *readHeaderTimeout)
+	log.Printf("  read_timeout: %v", *readTimeout)
+	log.Printf("  write_timeout: %v", *writeTimeout)
+	log.Printf("  idle_timeout: %v", *idleTimeout)
+	log.Printf("  max_header_bytes: %v", *maxHeaderBytes)
+	log.Printf("  max_uri_bytes: %v", *maxURIBytes)
+	log.Printf("  access_log: %v", *accessLog)
+	log.Printf("  access_log_format: %v", *accessLogFormat)
+	log.Printf("  log_level: %v", *logLevel)
+	log.Printf("  metrics_path: %v", *metricsPath)
+	log.Printf("  metrics_addr: %v", *metricsAddr)
 
 	// Start server
 	addr := fmt.Sprintf(":%v", *port)
@@ -34,11 +93,242 @@ func main() {
 		log.Fatal(s.ListenAndServe())
 	} else {
 		log.Printf("Starting GoHTTP server")
-		log.Printf("You can browse the website at http://localhost:%v/", *port)
+		scheme := "http"
+		if *tlsCert != "" {
+			scheme = "https"
+		}
+		log.Printf("You can browse the website at %v://localhost:%v/", scheme, *port)
 		s := &gohttp.Server{
-			Addr:    addr,
-			DocRoot: *docRoot,
+			Addr:              addr,
+			DocRoot:           *docRoot,
+			ReadHeaderTimeout: *readHeaderTimeout,
+			ReadTimeout:       unlimitedIfZero(*readTimeout),
+			WriteTimeout:      unlimitedIfZero(*writeTimeout),
+			IdleTimeout:       *idleTimeout,
+			MaxHeaderBytes:    *maxHeaderBytes,
+			MaxURIBytes:       *maxURIBytes,
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			log.Fatal(err)
+		}
+		s.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		if *accessLog != "" {
+			accessLog, err := openAccessLog(*accessLog, *accessLogFormat)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer accessLog.Close()
+			reopenOnSignal(accessLog, s.Logger)
+			s.AccessLog = accessLog
+		}
+		symlinkPolicy, err := gohttp.ParseSymlinkPolicy(*symlinks)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var hidePatterns []string
+		if *hide != "" {
+			hidePatterns = strings.Split(*hide, ",")
+		}
+		var writableDirs []string
+		if *writable != "" {
+			writableDirs = strings.Split(*writable, ",")
+		}
+		fileServer := func(root string) gohttp.Handler {
+			return &gohttp.FileHandler{
+				Root:           root,
+				AutoIndex:      *autoindex,
+				Hide:           hidePatterns,
+				Symlinks:       symlinkPolicy,
+				Writable:       writableDirs,
+				MaxUploadBytes: *maxUploadBytes,
+			}
+		}
+		s.Handler = fileServer(*docRoot)
+		if *uploadPath != "" {
+			if *uploadPath == "/" || path.Clean(*uploadPath) != *uploadPath || !strings.HasPrefix(*uploadPath, "/") {
+				log.Fatalf("invalid -upload_path %q: want a clean path below the root, e.g. /uploads", *uploadPath)
+			}
+			// Uploads are POSTed to the path, and served from under it
+			mux := gohttp.NewServeMux()
+			mux.Handle("/", s.Handler)
+			mux.Handle(*uploadPath, &gohttp.UploadHandler{
+				Dir:            filepath.Join(*docRoot, filepath.FromSlash(*uploadPath)),
+				MaxUploadBytes: *maxUploadBytes,
+			})
+			s.Handler = mux
+		}
+		if *redirects != "" {
+			rules, err := loadRedirects(*redirects)
+			if err != nil {
+				log.Fatal(err)
+			}
+			s.Redirects = rules
+		}
+		if *vhosts != "" {
+			virtualHosts, err := parseVirtualHosts(*vhosts, fileServer)
+			if err != nil {
+				log.Fatal(err)
+			}
+			s.VirtualHosts = virtualHosts
+		}
+		if *compress {
+			s.Compression = &gohttp.Compression{
+				MinSize: *compressMinSize,
+			}
+		}
+		servers := []*gohttp.Server{s}
+		if *metricsPath != "" || *metricsAddr != "" {
+			s.Metrics = gohttp.NewMetrics()
+			s.MetricsPath = *metricsPath
+		}
+		if *metricsAddr != "" {
+			// The admin server's own requests are left out of the metrics
+			adminMux := gohttp.NewServeMux()
+			adminMux.Handle("/metrics", s.Metrics)
+			admin := &gohttp.Server{
+				Addr:    *metricsAddr,
+				Handler: adminMux,
+				Logger:  s.Logger,
+			}
+			servers = append(servers, admin)
+			go func() {
+				if err := admin.ListenAndServe(); !errors.Is(err, gohttp.ErrServerClosed) {
+					log.Fatal(err)
+				}
+			}()
+		}
+		shutdownDone := shutdownOnSignal(*shutdownTimeout, servers...)
+		if *tlsCert != "" {
+			certStore, loadErr := loadCertificates(*tlsCert, *tlsKey)
+			if loadErr != nil {
+				log.Fatal(loadErr)
+			}
+			s.TLSConfig = &tls.Config{
+				GetCertificate: certStore.GetCertificate,
+			}
+			err = s.ListenAndServeTLS("", "")
+		} else {
+			err = s.ListenAndServe()
+		}
+		if !errors.Is(err, gohttp.ErrServerClosed) {
+			log.Fatal(err)
+		}
+		// Wait for the requests in flight to finish
+		<-shutdownDone
+	}
+}
+
+// shutdownOnSignal gracefully shuts down servers on SIGTERM or SIGINT,
+// giving requests in flight up to timeout to finish. The returned
+// channel is closed once the shutdown is over.
+func shutdownOnSignal(timeout time.Duration, servers ...*gohttp.Server) <-chan struct{} {
+	done := make(chan struct{})
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		defer close(done)
+		sig := <-sigc
+		log.Printf("Received %v, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		for _, s := range servers {
+			if err := s.Shutdown(ctx); err != nil {
+				log.Printf("Failed to shut down gracefully: %v", err)
+				s.Close()
+			}
+		}
+	}()
+	return done
+}
+
+// openAccessLog opens the access log at path, or on stdout
+// if path is "-", in the named format.
+func openAccessLog(path, formatName string) (*gohttp.AccessLog, error) {
+	format, err := gohttp.ParseAccessLogFormat(formatName)
+	if err != nil {
+		return nil, err
+	}
+	if path == "-" {
+		return gohttp.NewAccessLog(os.Stdout, format), nil
+	}
+	return gohttp.OpenAccessLog(path, format)
+}
+
+// unlimitedIfZero turns a timeout flag of 0, meaning no limit, into
+// the negative timeout the server takes for no limit, as it takes 0
+// for its default.
+func unlimitedIfZero(d time.Duration) time.Duration {
+	if d == 0 {
+		return -1
+	}
+	return d
+}
+
+// loadRedirects reads the redirect table at path. Each line holds
+// a rule as "code from to"; blank lines and lines starting with "#"
+// are ignored.
+func loadRedirects(path string) ([]gohttp.RedirectRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []gohttp.RedirectRule
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%v:%v: want \"code from to\": %q", path, i+1, line)
+		}
+		code, err := strconv.Atoi(fields[0])
+		if err != nil || (code != 301 && code != 302 && code != 307 && code != 308) {
+			return nil, fmt.Errorf("%v:%v: invalid redirect status code: %q", path, i+1, fields[0])
+		}
+		if !strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("%v:%v: redirected path must start with \"/\": %q", path, i+1, fields[1])
+		}
+		rules = append(rules, gohttp.RedirectRule{From: fields[1], To: fields[2], Code: code})
+	}
+	return rules, nil
+}
+
+// parseVirtualHosts parses comma-separated host=doc_root pairs into
+// a file server per host.
+func parseVirtualHosts(vhosts string, fileServer func(root string) gohttp.Handler) (map[string]gohttp.Handler, error) {
+	virtualHosts := make(map[string]gohttp.Handler)
+	for _, pair := range strings.Split(vhosts, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid virtual host, want host=doc_root: %q", pair)
+		}
+		fi, err := os.Stat(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("doc_root of %v is not a directory: %v", kv[0], kv[1])
+		}
+		virtualHosts[kv[0]] = fileServer(kv[1])
+	}
+	return virtualHosts, nil
+}
+
+// loadCertificates loads the certificates and keys listed in the
+// comma-separated certFiles and keyFiles into a certificate store.
+func loadCertificates(certFiles, keyFiles string) (*gohttp.CertStore, error) {
+	certs := strings.Split(certFiles, ",")
+	keys := strings.Split(keyFiles, ",")
+	if len(certs) != len(keys) {
+		return nil, fmt.Errorf("got %v certificate files but %v key files", len(certs), len(keys))
+	}
+	certStore := gohttp.NewCertStore()
+	for i := range certs {
+		if err := certStore.Add(certs[i], keys[i]); err != nil {
+			return nil, err
 		}
-		log.Fatal(s.ListenAndServe())
 	}
+	return certStore, nil
 }
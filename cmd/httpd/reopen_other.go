@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"log/slog"
+
+	"cse224/proj3/pkg/gohttp"
+)
+
+// reopenOnSignal does nothing on systems without SIGUSR1.
+func reopenOnSignal(accessLog *gohttp.AccessLog, logger *slog.Logger) {}
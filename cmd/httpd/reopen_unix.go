@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cse224/proj3/pkg/gohttp"
+)
+
+// reopenOnSignal reopens the file of accessLog on SIGUSR1,
+// which log rotation tools send after moving it away.
+func reopenOnSignal(accessLog *gohttp.AccessLog, logger *slog.Logger) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGUSR1)
+	go func() {
+		for range sigc {
+			if err := accessLog.Reopen(); err != nil {
+				logger.Error("failed to reopen access log", "err", err)
+			} else {
+				logger.Info("reopened access log")
+			}
+		}
+	}()
+}
@@ -1,3 +1,3 @@
 module cse224/proj3
 
-go 1.17
+go 1.25
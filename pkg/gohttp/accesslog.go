@@ -0,0 +1,227 @@
+package gohttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An AccessLogFormat is the format of the records of an AccessLog.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the Common Log Format of NCSA and Apache:
+	//   host ident user [time] "method url proto" status bytes
+	CommonLogFormat AccessLogFormat = iota
+
+	// CombinedLogFormat is the Common Log Format followed by the
+	// "Referer" and "User-Agent" headers of the request.
+	CombinedLogFormat
+
+	// JSONLogFormat writes each record as a JSON object on its own
+	// line, including how long the request took to serve.
+	JSONLogFormat
+)
+
+// ParseAccessLogFormat returns the AccessLogFormat named "common",
+// "combined" or "json".
+func ParseAccessLogFormat(name string) (AccessLogFormat, error) {
+	switch strings.ToLower(name) {
+	case "common", "clf":
+		return CommonLogFormat, nil
+	case "combined":
+		return CombinedLogFormat, nil
+	case "json":
+		return JSONLogFormat, nil
+	}
+	return 0, fmt.Errorf("unknown access log format: %q", name)
+}
+
+// An AccessLog writes a record for each request a server responds to.
+// It is safe for concurrent use.
+type AccessLog struct {
+	format AccessLogFormat
+
+	mu   sync.Mutex
+	w    io.Writer
+	path string   // "" if w was not opened by the AccessLog
+	f    *os.File // the file at path
+}
+
+// NewAccessLog returns an AccessLog writing records to w in format.
+func NewAccessLog(w io.Writer, format AccessLogFormat) *AccessLog {
+	return &AccessLog{format: format, w: w}
+}
+
+// OpenAccessLog returns an AccessLog appending records to the file
+// at path in format, creating the file if needed.
+func OpenAccessLog(path string, format AccessLogFormat) (*AccessLog, error) {
+	f, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessLog{format: format, w: f, path: path, f: f}, nil
+}
+
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+}
+
+// Reopen closes and reopens the file of an AccessLog created by
+// OpenAccessLog, so that records go to a new file after the old one
+// has been moved away, e.g. by logrotate. It does nothing for an
+// AccessLog created by NewAccessLog.
+func (l *AccessLog) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		return nil
+	}
+	f, err := openLogFile(l.path)
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	l.w = f
+	return nil
+}
+
+// Close closes the file of an AccessLog created by OpenAccessLog.
+func (l *AccessLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// accessRecord is what an AccessLog records about a request.
+type accessRecord struct {
+	time       time.Time // when the server started serving the request
+	remoteAddr string
+	method     string // "" if the request could not be read
+	url        string
+	proto      string
+	status     int
+	bytes      int64 // body bytes sent
+	duration   time.Duration
+	referer    string
+	userAgent  string
+}
+
+// newAccessRecord returns the record of req, whose serving started at start.
+func newAccessRecord(req *Request, start time.Time) *accessRecord {
+	return &accessRecord{
+		time:       start,
+		remoteAddr: req.RemoteAddr,
+		method:     req.Method,
+		url:        req.RequestURI,
+		proto:      req.Proto,
+		referer:    req.Header.Get("Referer"),
+		userAgent:  req.Header.Get("User-Agent"),
+	}
+}
+
+func (l *AccessLog) log(rec *accessRecord) error {
+	var line []byte
+	switch l.format {
+	case JSONLogFormat:
+		var err error
+		if line, err = rec.json(); err != nil {
+			return err
+		}
+	case CombinedLogFormat:
+		line = []byte(rec.common() + fmt.Sprintf(` "%s" "%s"`, logField(rec.referer), logField(rec.userAgent)))
+	default:
+		line = []byte(rec.common())
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.w.Write(line)
+	return err
+}
+
+// common formats rec in the Common Log Format, where unknown
+// fields are "-".
+func (rec *accessRecord) common() string {
+	host := rec.remoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		host = "-"
+	}
+	requestLine := "-"
+	if rec.method != "" {
+		requestLine = logEscape(rec.method + " " + rec.url + " " + rec.proto)
+	}
+	bytes := "-"
+	if rec.bytes > 0 {
+		bytes = strconv.FormatInt(rec.bytes, 10)
+	}
+	return fmt.Sprintf(`%s - - [%s] "%s" %d %s`,
+		host, rec.time.Format("02/Jan/2006:15:04:05 -0700"), requestLine, rec.status, bytes)
+}
+
+func (rec *accessRecord) json() ([]byte, error) {
+	return json.Marshal(struct {
+		Time       string  `json:"time"`
+		RemoteAddr string  `json:"remote_addr"`
+		Method     string  `json:"method,omitempty"`
+		URL        string  `json:"url,omitempty"`
+		Proto      string  `json:"proto,omitempty"`
+		Status     int     `json:"status"`
+		Bytes      int64   `json:"bytes"`
+		DurationMs float64 `json:"duration_ms"`
+		Referer    string  `json:"referer,omitempty"`
+		UserAgent  string  `json:"user_agent,omitempty"`
+	}{
+		Time:       rec.time.Format(time.RFC3339Nano),
+		RemoteAddr: rec.remoteAddr,
+		Method:     rec.method,
+		URL:        rec.url,
+		Proto:      rec.proto,
+		Status:     rec.status,
+		Bytes:      rec.bytes,
+		DurationMs: float64(rec.duration.Microseconds()) / 1000,
+		Referer:    rec.referer,
+		UserAgent:  rec.userAgent,
+	})
+}
+
+// logField returns the escaped header value s, or "-" if it is empty.
+func logField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return logEscape(s)
+}
+
+// logEscape escapes quotes, backslashes and non-printable bytes in s,
+// the way Apache does, so that a client can't forge log records.
+func logEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package gohttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	rec := &accessRecord{
+		time:       time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		remoteAddr: "192.0.2.1:54321",
+		method:     "GET",
+		url:        "/index.html",
+		proto:      "HTTP/1.1",
+		status:     200,
+		bytes:      2326,
+		duration:   1500 * time.Microsecond,
+		referer:    "http://example.com/",
+		userAgent:  `Agent "quoted"`,
+	}
+
+	var tests = []struct {
+		name     string
+		format   AccessLogFormat
+		rec      *accessRecord
+		lineWant string
+	}{
+		{
+			"Common",
+			CommonLogFormat,
+			rec,
+			`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326`,
+		},
+		{
+			"Combined",
+			CombinedLogFormat,
+			rec,
+			`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "http://example.com/" "Agent \"quoted\""`,
+		},
+		{
+			"JSON",
+			JSONLogFormat,
+			rec,
+			`{"time":"2000-10-10T13:55:36-07:00","remote_addr":"192.0.2.1:54321","method":"GET","url":"/index.html","proto":"HTTP/1.1","status":200,"bytes":2326,"duration_ms":1.5,"referer":"http://example.com/","user_agent":"Agent \"quoted\""}`,
+		},
+		{
+			"CombinedNoHeaders",
+			CombinedLogFormat,
+			&accessRecord{time: rec.time, remoteAddr: "192.0.2.1:54321", method: "GET", url: "/", proto: "HTTP/1.1", status: 304},
+			`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 304 - "-" "-"`,
+		},
+		{
+			"CommonUnreadRequest",
+			CommonLogFormat,
+			&accessRecord{time: rec.time, remoteAddr: "192.0.2.1:54321", status: 400},
+			`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "-" 400 -`,
+		},
+		{
+			"CommonEscaped",
+			CommonLogFormat,
+			&accessRecord{time: rec.time, remoteAddr: "192.0.2.1:54321", method: "GET", url: "/\"\x01", proto: "HTTP/1.1", status: 404},
+			`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /\"\x01 HTTP/1.1" 404 -`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewAccessLog(&buf, tt.format).log(tt.rec); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.lineWant+"\n" {
+				t.Fatalf("record got: %q, want: %q", got, tt.lineWant+"\n")
+			}
+		})
+	}
+}
+
+func TestAccessLogReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	l, err := OpenAccessLog(path, CommonLogFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	rec := &accessRecord{time: time.Now(), remoteAddr: "192.0.2.1:54321", status: 400}
+
+	if err := l.log(rec); err != nil {
+		t.Fatal(err)
+	}
+	// Rotate the log like logrotate does
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.log(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path + ".1", path} {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(content), "\n"); n != 1 {
+			t.Fatalf("%v has %v records, want: 1", filepath.Base(p), n)
+		}
+	}
+}
+
+func TestServerAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	s := &Server{
+		Addr:      ":0",
+		DocRoot:   "testdata",
+		AccessLog: NewAccessLog(&buf, JSONLogFormat),
+	}
+	roundTrip(t, s, "GET /index.html HTTP/1.1\r\nHost: test\r\nUser-Agent: test-agent\r\n\r\n"+
+		"HEAD /index.html HTTP/1.1\r\nHost: test\r\n\r\n"+
+		"BREW /index.html HTTP/1.1\r\nHost: test\r\n\r\n")
+
+	type record struct {
+		Method     string `json:"method"`
+		URL        string `json:"url"`
+		Status     int    `json:"status"`
+		Bytes      int64  `json:"bytes"`
+		RemoteAddr string `json:"remote_addr"`
+		UserAgent  string `json:"user_agent"`
+	}
+	recordsWant := []record{
+		{"GET", "/index.html", 200, 12, "pipe", "test-agent"},
+		{"HEAD", "/index.html", 200, 0, "pipe", ""},
+		{"", "", 501, 0, "pipe", ""},
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(recordsWant) {
+		t.Fatalf("got %v records, want: %v\n%v", len(lines), len(recordsWant), buf.String())
+	}
+	for i, line := range lines {
+		var got record
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != recordsWant[i] {
+			t.Fatalf("record got: %+v, want: %+v", got, recordsWant[i])
+		}
+	}
+}
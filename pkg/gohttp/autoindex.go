@@ -0,0 +1,237 @@
+package gohttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dirEntry is an entry of a directory listing.
+type dirEntry struct {
+	Name    string    `json:"name"` // with a trailing "/" for directories
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// autoIndexes reports whether f lists the contents of dir,
+// under the directory of r.
+func (f *FileHandler) autoIndexes(r *os.Root, dir string) bool {
+	if _, err := statIn(r, filepath.Join(dir, ".noautoindex")); err == nil {
+		return false
+	}
+	if f.AutoIndex {
+		return true
+	}
+	_, err := statIn(r, filepath.Join(dir, ".autoindex"))
+	return err == nil
+}
+
+// listDirectory makes res a listing of the directory dir under the
+// directory of r, found at urlPath. The listing is sorted as asked for by the "sort" (name,
+// size or mtime) and "order" (asc or desc) parameters of rawQuery.
+// It is in JSON if the client prefers it, and in HTML otherwise.
+func (f *FileHandler) listDirectory(res *Response, r *os.Root, dir, urlPath, rawQuery string) {
+	req := res.Request
+	entries, err := f.readDir(r, dir)
+	if err != nil {
+		req.logger().Warn("failed to list directory", "path", dir, "err", err)
+		res.HandleNotFound(req)
+		return
+	}
+	query, _ := url.ParseQuery(rawQuery)
+	sortBy, desc := query.Get("sort"), query.Get("order") == "desc"
+	sortDirEntries(entries, sortBy, desc)
+
+	var body []byte
+	if prefersJSON(req.Header.list("Accept")) {
+		body, err = json.Marshal(struct {
+			Path    string     `json:"path"`
+			Entries []dirEntry `json:"entries"`
+		}{urlPath, entries})
+		body = append(body, '\n')
+		res.Header.Set("Content-Type", "application/json")
+	} else {
+		body, err = renderListing(urlPath, entries, sortBy, desc)
+		res.Header.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err != nil {
+		req.logger().Warn("failed to render directory listing", "path", dir, "err", err)
+		res.HandleNotFound(req)
+		return
+	}
+
+	res.Header.Set("Date", FormatTime(time.Now()))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	addVary(res.Header, "Accept")
+	if req.Close {
+		res.Header.Set("Connection", "close")
+	}
+	res.Body = bytes.NewReader(body)
+}
+
+// readDir returns the entries of dir under the directory of r, leaving
+// out those f wouldn't serve: hidden files, and symbolic links f doesn't
+// follow. Links are listed with the metadata of their targets.
+func (f *FileHandler) readDir(r *os.Root, dir string) ([]dirEntry, error) {
+	name, err := rootName(r, dir)
+	if err != nil {
+		return nil, err
+	}
+	d, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	des, err := d.ReadDir(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]dirEntry, 0, len(des))
+	for _, de := range des {
+		if f.hidden(de.Name()) {
+			continue
+		}
+		p, err := f.follow(r.Name(), filepath.Join(dir, de.Name()))
+		if err != nil {
+			continue
+		}
+		fi, err := statIn(r, p)
+		if err != nil {
+			// Removed since the directory was read, or a dangling link
+			continue
+		}
+		e := dirEntry{
+			Name:    de.Name(),
+			ModTime: fi.ModTime().UTC().Truncate(time.Second),
+			IsDir:   fi.IsDir(),
+		}
+		if e.IsDir {
+			e.Name += "/"
+		} else {
+			e.Size = fi.Size()
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// sortDirEntries sorts entries by name, size or mtime,
+// breaking ties by name.
+func sortDirEntries(entries []dirEntry, sortBy string, desc bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "mtime":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// prefersJSON reports whether the "Accept" header value accept
+// ranks application/json above text/html.
+func prefersJSON(accept string) bool {
+	qJSON, qHTML := 0.0, 0.0
+	for _, elem := range strings.Split(accept, ",") {
+		params := strings.Split(elem, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		switch mediaType {
+		case "application/json":
+			qJSON = q
+		case "text/html":
+			qHTML = q
+		}
+	}
+	return qJSON > 0 && qJSON > qHTML
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead>
+<tr>{{range .Columns}}<th><a href="?sort={{.Sort}}&amp;order={{.Order}}">{{.Title}}</a></th>{{end}}</tr>
+</thead>
+<tbody>
+{{- if ne .Path "/"}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{.Size}}</td><td>{{.ModTime}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// renderListing renders entries of the directory at urlPath as HTML,
+// with links to sort by each column.
+func renderListing(urlPath string, entries []dirEntry, sortBy string, desc bool) ([]byte, error) {
+	if sortBy != "size" && sortBy != "mtime" {
+		sortBy = "name"
+	}
+	type column struct{ Title, Sort, Order string }
+	var columns []column
+	for _, c := range []column{{"Name", "name", ""}, {"Size", "size", ""}, {"Last modified", "mtime", ""}} {
+		// Clicking the current sort column reverses the order
+		c.Order = "asc"
+		if c.Sort == sortBy && !desc {
+			c.Order = "desc"
+		}
+		columns = append(columns, c)
+	}
+	type row struct{ Href, Name, Size, ModTime string }
+	rows := make([]row, 0, len(entries))
+	for _, e := range entries {
+		size := "-"
+		if !e.IsDir {
+			size = strconv.FormatInt(e.Size, 10)
+		}
+		rows = append(rows, row{
+			Href:    (&url.URL{Path: e.Name}).String(),
+			Name:    e.Name,
+			Size:    size,
+			ModTime: e.ModTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := listingTemplate.Execute(&buf, struct {
+		Path    string
+		Columns []column
+		Entries []row
+	}{urlPath, columns, rows})
+	return buf.Bytes(), err
+}
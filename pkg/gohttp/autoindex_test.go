@@ -0,0 +1,189 @@
+package gohttp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeListingDir creates a directory tree to list, and returns its path.
+func makeListingDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	base := time.Date(2023, 3, 22, 12, 0, 0, 0, time.UTC)
+	files := []struct {
+		name    string
+		size    int
+		modTime time.Time
+	}{
+		{"b.txt", 10, base.Add(time.Hour)},
+		{"a.txt", 3, base.Add(2 * time.Hour)},
+		{"<c>.html", 5, base},
+		{".hidden", 1, base},
+		{"off/.noautoindex", 0, base},
+		{"on/.autoindex", 0, base},
+		{"on/file.txt", 1, base},
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", f.size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestAutoIndexJSON(t *testing.T) {
+	root := makeListingDir(t)
+
+	var tests = []struct {
+		name       string
+		handler    *FileHandler
+		url        string
+		statusWant int
+		namesWant  []string
+	}{
+		{"ByName", &FileHandler{Root: root, AutoIndex: true}, "/", 200, []string{"<c>.html", "a.txt", "b.txt", "off/", "on/"}},
+		{"BySizeDesc", &FileHandler{Root: root, AutoIndex: true}, "/?sort=size&order=desc", 200, []string{"b.txt", "<c>.html", "a.txt", "on/", "off/"}},
+		{"ByMtime", &FileHandler{Root: root, AutoIndex: true}, "/?sort=mtime", 200, []string{"<c>.html", "b.txt", "a.txt", "off/", "on/"}},
+		{"ShowHidden", &FileHandler{Root: root, AutoIndex: true, ShowHidden: true}, "/", 200, []string{".hidden", "<c>.html", "a.txt", "b.txt", "off/", "on/"}},
+		{"Off", &FileHandler{Root: root}, "/", 404, nil},
+		{"DirectoryOptOut", &FileHandler{Root: root, AutoIndex: true}, "/off/", 404, nil},
+		{"DirectoryOptIn", &FileHandler{Root: root}, "/on/", 200, []string{"file.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: "GET", URL: mustParseURL(tt.url), Proto: "HTTP/1.1", Header: Header{"Accept": {"application/json"}}}
+			w := newRecorder()
+			tt.handler.ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			if tt.statusWant != 200 {
+				return
+			}
+			if got := w.header.Get("Content-Type"); got != "application/json" {
+				t.Fatalf("content type got: %q, want: %q", got, "application/json")
+			}
+			var listing struct {
+				Entries []dirEntry `json:"entries"`
+			}
+			if err := json.Unmarshal(w.body.Bytes(), &listing); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, e := range listing.Entries {
+				names = append(names, e.Name)
+			}
+			if strings.Join(names, " ") != strings.Join(tt.namesWant, " ") {
+				t.Fatalf("entries got: %q, want: %q", names, tt.namesWant)
+			}
+		})
+	}
+}
+
+func TestAutoIndexHTML(t *testing.T) {
+	root := makeListingDir(t)
+	h := &FileHandler{Root: root, AutoIndex: true}
+	req := &Request{Method: "GET", URL: mustParseURL("/?sort=size"), Proto: "HTTP/1.1", Header: Header{"Accept": {"text/html,application/json;q=0.9"}}}
+	w := newRecorder()
+	h.ServeGoHTTP(w, req)
+	if w.status != 200 {
+		t.Fatalf("status code got: %v, want: 200", w.status)
+	}
+	if got := w.header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("content type got: %q, want: %q", got, "text/html; charset=utf-8")
+	}
+	if got := w.header.Get("Vary"); got != "Accept" {
+		t.Fatalf("vary got: %q, want: %q", got, "Accept")
+	}
+	body := w.body.String()
+	for _, want := range []string{
+		"<title>Index of /</title>",
+		`<a href="?sort=size&amp;order=desc">Size</a>`,
+		`<a href="?sort=name&amp;order=asc">Name</a>`,
+		`<tr><td><a href="a.txt">a.txt</a></td><td>3</td><td>2023-03-22 14:00:00</td></tr>`,
+		`<a href="%3Cc%3E.html">&lt;c&gt;.html</a>`,
+		`<a href="on/">on/</a></td><td>-</td>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("listing missing %q in:\n%v", want, body)
+		}
+	}
+	if strings.Contains(body, ".hidden") || strings.Contains(body, `href="../"`) {
+		t.Errorf("listing got hidden file or parent link:\n%v", body)
+	}
+}
+
+func TestAutoIndexSymlinks(t *testing.T) {
+	root := makeListingDir(t)
+	outside := t.TempDir()
+	for link, target := range map[string]string{
+		"etc":      outside,
+		"alias":    "on",
+		"link.txt": "b.txt",
+		"secret":   ".hidden",
+		"dangling": "missing.txt",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name      string
+		symlinks  SymlinkPolicy
+		entryWant map[string]dirEntry // the entries by name, leaving out mod times
+	}{
+		{"Follow", SymlinksFollow, map[string]dirEntry{
+			"<c>.html": {Size: 5},
+			"a.txt":    {Size: 3},
+			"b.txt":    {Size: 10},
+			"link.txt": {Size: 10},
+			"alias/":   {IsDir: true},
+			"off/":     {IsDir: true},
+			"on/":      {IsDir: true},
+		}},
+		{"Deny", SymlinksDeny, map[string]dirEntry{
+			"<c>.html": {Size: 5},
+			"a.txt":    {Size: 3},
+			"b.txt":    {Size: 10},
+			"off/":     {IsDir: true},
+			"on/":      {IsDir: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &FileHandler{Root: root, AutoIndex: true, Symlinks: tt.symlinks}
+			req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{"Accept": {"application/json"}}}
+			w := newRecorder()
+			f.ServeGoHTTP(w, req)
+			var listing struct {
+				Entries []dirEntry `json:"entries"`
+			}
+			if err := json.Unmarshal(w.body.Bytes(), &listing); err != nil {
+				t.Fatal(err)
+			}
+			if len(listing.Entries) != len(tt.entryWant) {
+				t.Fatalf("entries got: %+v, want: %+v", listing.Entries, tt.entryWant)
+			}
+			for _, e := range listing.Entries {
+				want, ok := tt.entryWant[e.Name]
+				if !ok || e.Size != want.Size || e.IsDir != want.IsDir {
+					t.Fatalf("entry got: %+v, want: %+v", e, want)
+				}
+			}
+		})
+	}
+}
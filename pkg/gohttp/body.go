@@ -0,0 +1,314 @@
+package gohttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxDrainBytes is how much of an unread request body the server
+// discards to reuse the connection. Anything larger closes it instead.
+const maxDrainBytes = 256 << 10
+
+// maxChunkLineBytes is the limit on the size of the line starting
+// a chunk, including chunk extensions and the line end.
+const maxChunkLineBytes = 4 << 10
+
+// ErrBodyReadAfterClose is returned when reading a request Body
+// after it has been closed.
+var ErrBodyReadAfterClose = errors.New("gohttp: invalid Read on closed Body")
+
+// NoBody is the Body of a request without a body.
+// It is always empty, and closing it does nothing.
+var NoBody = noBody{}
+
+type noBody struct{}
+
+func (noBody) Read([]byte) (int, error) { return 0, io.EOF }
+func (noBody) Close() error             { return nil }
+
+// body is the Body of a request with a body. It reads the body from
+// the connection, framed by either "Content-Length" or the chunked
+// transfer coding, and never past its end.
+type body struct {
+	src    io.Reader // an io.LimitedReader or a chunkedReader
+	closed bool
+	err    error // sticky error from src, including io.EOF
+
+	// onFirstRead, if not nil, is called before the handler first
+	// reads the body, to send "100 Continue" to a client waiting for it.
+	onFirstRead func() error
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	if f := b.onFirstRead; f != nil {
+		b.onFirstRead = nil
+		if err := f(); err != nil {
+			return 0, err
+		}
+	}
+	return b.read(p)
+}
+
+func (b *body) read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.src.Read(p)
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// Close marks the body as closed. Unread bytes are left for the
+// server to drain before it reads the next request.
+func (b *body) Close() error {
+	b.closed = true
+	return nil
+}
+
+// drain discards what is left of the body, so that the next request
+// on the connection can be read. It returns an error if the body is
+// malformed or too large to be worth discarding, in which case the
+// connection must be closed.
+func (b *body) drain() error {
+	buf := make([]byte, 4096)
+	var n int64
+	for n <= maxDrainBytes {
+		m, err := b.read(buf)
+		n += int64(m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("unread request body larger than %v bytes", maxDrainBytes)
+}
+
+// readBody sets up req.Body to read the body following the headers
+// of req from br, according to the framing headers of req. The trailer
+// of a chunked body may take up to maxTrailerBytes.
+func readBody(req *Request, br *bufio.Reader, maxTrailerBytes int) error {
+	req.Body = NoBody
+	hasTE := req.Header.has("Transfer-Encoding")
+	hasCL := req.Header.has("Content-Length")
+
+	if hasTE {
+		// A request with both could be read differently by a proxy
+		// in front of us, so refuse it (RFC 9112, Section 6.3).
+		if hasCL {
+			return fmt.Errorf("both Transfer-Encoding and Content-Length found")
+		}
+		te := req.Header.list("Transfer-Encoding")
+		if !strings.EqualFold(strings.TrimSpace(te), "chunked") {
+			return &statusError{statusNotImplemented, fmt.Sprintf("unsupported transfer encoding: %v", te)}
+		}
+		req.ContentLength = -1
+		req.Body = &body{src: &chunkedReader{br: br, req: req, maxTrailerBytes: maxTrailerBytes}}
+		return nil
+	}
+
+	if hasCL {
+		cl := req.Header.Values("Content-Length")
+		for _, v := range cl[1:] {
+			// Repeated fields must agree (RFC 9110, Section 8.6)
+			if v != cl[0] {
+				return fmt.Errorf("conflicting content lengths: %q", cl)
+			}
+		}
+		n, err := parseContentLength(cl[0])
+		if err != nil {
+			return err
+		}
+		req.ContentLength = n
+		if n > 0 {
+			req.Body = &body{src: &io.LimitedReader{R: eofReader{br}, N: n}}
+		}
+	}
+	return nil
+}
+
+func parseContentLength(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid content length: %q", s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid content length: %q", s)
+	}
+	return n, nil
+}
+
+// eofReader turns an io.EOF from r into io.ErrUnexpectedEOF,
+// for bodies that are cut short by the client.
+type eofReader struct {
+	r io.Reader
+}
+
+func (er eofReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// chunkedReader decodes a body sent with the chunked transfer coding.
+// Trailer fields after the last chunk are stored in req.Trailer.
+type chunkedReader struct {
+	br  *bufio.Reader
+	req *Request
+	n   int64 // bytes left in the current chunk
+	err error
+
+	maxTrailerBytes int // limit on the trailer size, line ends included
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err == nil && cr.n == 0 {
+		cr.err = cr.beginChunk()
+	}
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if int64(len(p)) > cr.n {
+		p = p[:cr.n]
+	}
+	n, err := cr.br.Read(p)
+	cr.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && cr.n == 0 {
+		err = cr.endChunk()
+	}
+	cr.err = err
+	return n, err
+}
+
+// beginChunk reads the size line of the next chunk.
+// After the last chunk, it reads the trailer and returns io.EOF.
+func (cr *chunkedReader) beginChunk() error {
+	line, err := readLineLimit(cr.br, maxChunkLineBytes)
+	if errors.Is(err, errLineTooLong) {
+		return fmt.Errorf("chunk size line longer than %v bytes", maxChunkLineBytes)
+	}
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	// Chunk extensions are allowed, but have no meaning to us
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = strings.TrimRight(line[:i], " \t")
+	}
+	size, err := parseChunkSize(line)
+	if err != nil {
+		return err
+	}
+	if size > 0 {
+		cr.n = size
+		return nil
+	}
+	if err := cr.readTrailer(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// parseChunkSize parses the size of a chunk, which is only hex digits
+// (RFC 9112, Section 7.1). Signs and spaces, which some proxies
+// would read differently, are refused.
+func parseChunkSize(s string) (int64, error) {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return !isHexDigit(r) }) != -1 {
+		return 0, fmt.Errorf("invalid chunk size: %q", s)
+	}
+	size, err := strconv.ParseUint(s, 16, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chunk size: %q", s)
+	}
+	return int64(size), nil
+}
+
+func isHexDigit(r rune) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
+// endChunk reads the line end following the data of a chunk.
+func (cr *chunkedReader) endChunk() error {
+	line, err := readLineLimit(cr.br, maxChunkLineBytes)
+	if errors.Is(err, errLineTooLong) {
+		return fmt.Errorf("invalid chunk end: line longer than %v bytes", maxChunkLineBytes)
+	}
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if line != "" {
+		return fmt.Errorf("invalid chunk end: %q", line)
+	}
+	return nil
+}
+
+// readTrailer reads the trailer fields after the last chunk, which
+// may take up to maxTrailerBytes, as headers do.
+func (cr *chunkedReader) readTrailer() error {
+	trailerBytes := 0
+	for {
+		line, err := readLineLimit(cr.br, cr.maxTrailerBytes-trailerBytes)
+		if errors.Is(err, errLineTooLong) {
+			return &statusError{statusRequestHeaderFieldsTooLarge, fmt.Sprintf("trailer larger than %v bytes", cr.maxTrailerBytes)}
+		}
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		trailerBytes += len(line) + 2
+		if line == "" {
+			return nil
+		}
+		key, value, err := parseHeaderLine(line)
+		if err != nil {
+			return fmt.Errorf("invalid trailer field: %q", line)
+		}
+		if cr.req.Trailer == nil {
+			cr.req.Trailer = make(Header)
+		}
+		cr.req.Trailer.Add(key, value)
+	}
+}
+
+// chunkedWriter encodes what is written to it with the chunked
+// transfer coding. Close writes the last chunk, but doesn't close w.
+type chunkedWriter struct {
+	w io.Writer
+}
+
+func (cw *chunkedWriter) Write(p []byte) (int, error) {
+	// An empty chunk would mark the end of the body
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(cw.w, "%x\r\n", len(p)); err != nil {
+		return 0, err
+	}
+	n, err := cw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if _, err := io.WriteString(cw.w, "\r\n"); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func (cw *chunkedWriter) Close() error {
+	_, err := io.WriteString(cw.w, "0\r\n\r\n")
+	return err
+}
@@ -0,0 +1,291 @@
+package gohttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRequestBody(t *testing.T) {
+	var tests = []struct {
+		name              string
+		reqText           string
+		bodyWant          string
+		contentLengthWant int64
+		trailerWant       Header
+	}{
+		{
+			"NoBody",
+			"GET / HTTP/1.1\r\nHost: test\r\n\r\n",
+			"",
+			0,
+			nil,
+		},
+		{
+			"ContentLength",
+			"POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 12\r\n\r\nhello, world",
+			"hello, world",
+			12,
+			nil,
+		},
+		{
+			"Chunked",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"7\r\nhello, \r\n" +
+				"5 ;ext=1\r\nworld\r\n" +
+				"0\r\n\r\n",
+			"hello, world",
+			-1,
+			nil,
+		},
+		{
+			"ChunkedTrailer",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"c\r\nhello, world\r\n" +
+				"0\r\n" +
+				"checksum: abc\r\n" +
+				"\r\n",
+			"hello, world",
+			-1,
+			Header{
+				"Checksum": {"abc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A request after the body must be readable too
+			br := bufio.NewReader(strings.NewReader(tt.reqText + "GET /next HTTP/1.1\r\nHost: test\r\n\r\n"))
+			req, _, err := ReadRequest(br)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.ContentLength != tt.contentLengthWant {
+				t.Fatalf("content length got: %v, want: %v", req.ContentLength, tt.contentLengthWant)
+			}
+			bodyGot, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bodyGot) != tt.bodyWant {
+				t.Fatalf("body got: %q, want: %q", bodyGot, tt.bodyWant)
+			}
+			if !reflect.DeepEqual(req.Trailer, tt.trailerWant) {
+				t.Fatalf("trailer got: %v, want: %v", req.Trailer, tt.trailerWant)
+			}
+
+			next, _, err := ReadRequest(br)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.URL.Path != "/next" {
+				t.Fatalf("next request path got: %q, want: %q", next.URL.Path, "/next")
+			}
+		})
+	}
+}
+
+func TestReadBadRequestBody(t *testing.T) {
+	var tests = []struct {
+		name       string
+		reqText    string
+		statusWant int
+	}{
+		{
+			"InvalidContentLength",
+			"POST / HTTP/1.1\r\nHost: test\r\nContent-Length: -1\r\n\r\n",
+			400,
+		},
+		{
+			"ConflictingContentLengths",
+			"POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\n",
+			400,
+		},
+		{
+			"BothFramings",
+			"POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 1\r\nTransfer-Encoding: chunked\r\n\r\n",
+			400,
+		},
+		{
+			"UnknownTransferEncoding",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: gzip\r\n\r\n",
+			501,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqGot, _, err := ReadRequest(bufio.NewReader(strings.NewReader(tt.reqText)))
+			checkBadRequest(t, err, reqGot)
+			if status := errorStatus(err); status != tt.statusWant {
+				t.Fatalf("error status got: %v, want: %v", status, tt.statusWant)
+			}
+		})
+	}
+}
+
+func TestReadMalformedBody(t *testing.T) {
+	var tests = []struct {
+		name    string
+		reqText string
+	}{
+		{
+			"Truncated",
+			"POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 12\r\n\r\nhello",
+		},
+		{
+			"InvalidChunkSize",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\nhello\r\n0\r\n\r\n",
+		},
+		{
+			"MissingChunkEnd",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello, world\r\n0\r\n\r\n",
+		},
+		{
+			"TruncatedChunk",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\nc\r\nhello",
+		},
+		{
+			"SignedChunkSize",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n+5\r\nhello\r\n-0\r\n\r\n",
+		},
+		{
+			"NegativeLastChunk",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n-0\r\n\r\n",
+		},
+		{
+			"SpaceBeforeChunkSize",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n 5\r\nhello\r\n0\r\n\r\n",
+		},
+		{
+			"HexPrefixChunkSize",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n0x5\r\nhello\r\n0\r\n\r\n",
+		},
+		{
+			"EmptyChunkSize",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n;ext\r\nhello\r\n0\r\n\r\n",
+		},
+		{
+			"LongChunkSizeLine",
+			"POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n5;" + strings.Repeat("x", maxChunkLineBytes) + "\r\nhello\r\n0\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _, err := ReadRequest(bufio.NewReader(strings.NewReader(tt.reqText)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadAll(req.Body); err == nil {
+				t.Fatalf("got no error reading a malformed body")
+			}
+		})
+	}
+}
+
+func TestReadLargeTrailer(t *testing.T) {
+	reqText := "POST / HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n" +
+		strings.Repeat("X-Padding: "+strings.Repeat("x", 50)+"\r\n", 10) + "\r\n"
+	req, _, err := readRequest(bufio.NewReader(strings.NewReader(reqText)), 256, defaultMaxURIBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(req.Body)
+	if status := errorStatus(err); err == nil || status != statusRequestHeaderFieldsTooLarge {
+		t.Fatalf("error got: %v (status %v), want status %v", err, status, statusRequestHeaderFieldsTooLarge)
+	}
+}
+
+func TestBodyDrain(t *testing.T) {
+	s := &Server{
+		Addr: ":0",
+		Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+			// Only read part of the body, and close it
+			buf := make([]byte, 2)
+			req.Body.Read(buf)
+			req.Body.Close()
+			io.WriteString(w, req.URL.Path)
+		}),
+	}
+	resText := roundTrip(t, s,
+		"POST /first HTTP/1.1\r\nHost: test\r\nContent-Length: 12\r\n\r\nhello, world"+
+			"POST /second HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"+
+			"GET /third HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+	for _, want := range []string{"\r\n\r\n/first", "\r\n\r\n/second", "\r\n\r\n/third"} {
+		if !strings.Contains(resText, want) {
+			t.Fatalf("response is missing %q\ngot: %q", want, resText)
+		}
+	}
+	if strings.Contains(resText, "400 Bad Request") {
+		t.Fatalf("body was misread as a request\ngot: %q", resText)
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/echo", func(w ResponseWriter, req *Request) {
+		io.Copy(w, req.Body)
+	})
+	mux.HandleFunc("/reject", func(w ResponseWriter, req *Request) {
+		w.WriteHeader(413)
+	})
+	s := &Server{Addr: ":0", Handler: mux}
+	addr, _ := startServer(t, s)
+
+	dial := func(t *testing.T) (net.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+
+	t.Run("Continue", func(t *testing.T) {
+		conn, br := dial(t)
+		io.WriteString(conn, "POST /echo HTTP/1.1\r\nHost: test\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+		for _, want := range []string{"HTTP/1.1 100 Continue", ""} {
+			if line, err := ReadLine(br); err != nil || line != want {
+				t.Fatalf("line got: %q %v, want: %q", line, err, want)
+			}
+		}
+		io.WriteString(conn, "hello")
+		if statusLine, body := readResponse(t, br); statusLine != "HTTP/1.1 200 OK" || body != "hello" {
+			t.Fatalf("response got: %q %q, want: %q %q", statusLine, body, "HTTP/1.1 200 OK", "hello")
+		}
+
+		// The connection is kept alive
+		io.WriteString(conn, "POST /echo HTTP/1.1\r\nHost: test\r\nContent-Length: 2\r\n\r\nhi")
+		if _, body := readResponse(t, br); body != "hi" {
+			t.Fatalf("body got: %q, want: %q", body, "hi")
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		conn, br := dial(t)
+		io.WriteString(conn, "POST /reject HTTP/1.1\r\nHost: test\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+		resText, err := io.ReadAll(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(resText), "HTTP/1.1 413 Content Too Large\r\nConnection: close\r\n") {
+			t.Fatalf("response got: %q, want a final 413 closing the connection", resText)
+		}
+	})
+
+	t.Run("UnknownExpectation", func(t *testing.T) {
+		conn, br := dial(t)
+		io.WriteString(conn, "POST /echo HTTP/1.1\r\nHost: test\r\nExpect: 200-ok\r\nContent-Length: 5\r\n\r\nhello")
+		if statusLine, _ := readResponse(t, br); statusLine != "HTTP/1.1 417 Expectation Failed" {
+			t.Fatalf("status line got: %q, want: %q", statusLine, "HTTP/1.1 417 Expectation Failed")
+		}
+	})
+}
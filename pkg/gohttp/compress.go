@@ -0,0 +1,309 @@
+package gohttp
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressibleTypes are the MIME types compressed when
+// Compression.Types is empty. A type ending in "/*" matches
+// every subtype.
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/xhtml+xml",
+	"image/svg+xml",
+}
+
+// Compression configures the on-the-fly compression of responses.
+type Compression struct {
+	// MinSize is the smallest "Content-Length" worth compressing.
+	// Responses of unknown length are always compressed.
+	MinSize int64
+
+	// Types lists the MIME types to compress, e.g. "text/html" or
+	// "text/*". If empty, common text-based types are compressed.
+	Types []string
+
+	// Level is the compression level, from 1 (best speed) to
+	// 9 (best compression). Zero means the default level.
+	Level int
+}
+
+// compressible reports whether contentType is one of the types c compresses.
+func (c *Compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	types := c.Types
+	if len(types) == 0 {
+		types = defaultCompressibleTypes
+	}
+	for _, t := range types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate returns an error if c can't be used to compress responses.
+func (c *Compression) validate() error {
+	if c.Level != 0 && (c.Level < gzip.BestSpeed || c.Level > gzip.BestCompression) {
+		return fmt.Errorf("invalid compression level %v", c.Level)
+	}
+	return nil
+}
+
+func (c *Compression) level() int {
+	if c.Level == 0 {
+		return gzip.DefaultCompression
+	}
+	return c.Level
+}
+
+// Compress returns a handler that compresses the responses of h with
+// gzip or deflate, as negotiated with the "Accept-Encoding" header of
+// each request and configured by c. It panics if c is invalid.
+func Compress(h Handler, c *Compression) Handler {
+	if err := c.validate(); err != nil {
+		panic("gohttp: " + err.Error())
+	}
+	return HandlerFunc(func(w ResponseWriter, req *Request) {
+		cw := &compressWriter{w: w, req: req, c: c}
+		h.ServeGoHTTP(cw, req)
+		if err := cw.close(); err != nil {
+			req.logger().Warn("failed to finish compressed response", "err", err)
+		}
+	})
+}
+
+// acceptedEncoding is an element of an "Accept-Encoding" header.
+type acceptedEncoding struct {
+	coding string
+	q      float64
+}
+
+// parseAcceptEncoding parses the value of an "Accept-Encoding" header.
+// Elements with an invalid q-value are ignored.
+func parseAcceptEncoding(s string) []acceptedEncoding {
+	var accepted []acceptedEncoding
+	for _, elem := range strings.Split(s, ",") {
+		params := strings.Split(elem, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "q" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || v < 0 || v > 1 {
+				valid = false
+				break
+			}
+			q = v
+		}
+		if valid {
+			accepted = append(accepted, acceptedEncoding{coding, q})
+		}
+	}
+	return accepted
+}
+
+// negotiateEncoding picks the content coding to use from supported,
+// listed in order of preference, given the "Accept-Encoding" header
+// value accept. It returns "" when the response should be sent as is.
+func negotiateEncoding(accept string, supported ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return ""
+	}
+	accepted := parseAcceptEncoding(accept)
+	qOf := func(coding string) float64 {
+		wildcard := -1.0
+		for _, a := range accepted {
+			if a.coding == coding {
+				return a.q
+			}
+			if a.coding == "*" {
+				wildcard = a.q
+			}
+		}
+		if wildcard >= 0 {
+			return wildcard
+		}
+		return 0
+	}
+
+	candidates := make([]acceptedEncoding, 0, len(supported))
+	for _, coding := range supported {
+		if q := qOf(coding); q > 0 {
+			candidates = append(candidates, acceptedEncoding{coding, q})
+		}
+	}
+	// Keep the order of preference among equal q-values
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].coding
+}
+
+// addVary adds field to the "Vary" header of h, unless already listed.
+func addVary(h Header, field string) {
+	vary := h.list("Vary")
+	for _, f := range strings.Split(vary, ",") {
+		f = strings.TrimSpace(f)
+		if f == "*" || strings.EqualFold(f, field) {
+			return
+		}
+	}
+	if vary == "" {
+		h.Set("Vary", field)
+	} else {
+		h.Set("Vary", vary+", "+field)
+	}
+}
+
+// weakenETag makes the "ETag" of h, if any, a weak validator.
+// The compressed representation has different bytes,
+// so it can't keep the strong validator of the original.
+func weakenETag(h Header) {
+	if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("Etag", "W/"+etag)
+	}
+}
+
+// compressWriter is the ResponseWriter of a Compress handler. It decides
+// whether to compress once the status code and headers are known.
+type compressWriter struct {
+	w   ResponseWriter
+	req *Request
+	c   *Compression
+
+	decided bool
+	enc     io.WriteCloser // nil when the response is not compressed
+	head    bool           // the response to HEAD is compressed, so its body is dropped
+}
+
+func (cw *compressWriter) Header() Header {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	// Interim responses leave the decision to the final one
+	if !cw.decided && !isInterim(statusCode) {
+		cw.decide(statusCode)
+	}
+	cw.w.WriteHeader(statusCode)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.WriteHeader(statusOK)
+	}
+	if cw.head {
+		return len(p), nil
+	}
+	if cw.enc == nil {
+		return cw.w.Write(p)
+	}
+	return cw.enc.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.enc != nil {
+		if f, ok := cw.enc.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := cw.w.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide sets up compression for a response with statusCode,
+// if it is worth it and accepted by the client.
+func (cw *compressWriter) decide(statusCode int) {
+	cw.decided = true
+	h := cw.w.Header()
+	if h.has("Content-Encoding") || !cw.c.compressible(h.Get("Content-Type")) {
+		return
+	}
+	// From here on, the response depends on "Accept-Encoding", and so
+	// do a 304 standing for it and a 206 sending part of it, which
+	// are never compressed themselves
+	addVary(h, "Accept-Encoding")
+	if !bodyAllowed(statusCode) || statusCode == statusPartialContent {
+		return
+	}
+	if n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil && n < cw.c.MinSize {
+		return
+	}
+
+	coding := negotiateEncoding(cw.req.Header.list("Accept-Encoding"), "gzip", "deflate")
+	if coding != "" && cw.req.Method == "HEAD" {
+		// Same headers as GET, but without a body to compress,
+		// the compressed length is left out
+		h.Set("Content-Encoding", coding)
+		cw.head = true
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		weakenETag(h)
+		return
+	}
+	var err error
+	switch coding {
+	case "gzip":
+		cw.enc, err = gzip.NewWriterLevel(cw.w, cw.c.level())
+		h.Set("Content-Encoding", "gzip")
+	case "deflate":
+		// "deflate" means the zlib format (RFC 9110, Section 8.4.1.2),
+		// not a raw compress/flate stream
+		cw.enc, err = zlib.NewWriterLevel(cw.w, cw.c.level())
+		h.Set("Content-Encoding", "deflate")
+	default:
+		return
+	}
+	if err != nil {
+		cw.enc = nil
+		h.Del("Content-Encoding")
+		return
+	}
+	// The compressed length is unknown until the body is written,
+	// and byte ranges would apply to the uncompressed file.
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	weakenETag(h)
+}
+
+// close finishes the compressed stream, if any.
+func (cw *compressWriter) close() error {
+	if cw.head {
+		// Send the headers now, so that no "Content-Length"
+		// is set for the body dropped
+		if f, ok := cw.w.(Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	return cw.enc.Close()
+}
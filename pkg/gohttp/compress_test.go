@@ -0,0 +1,225 @@
+package gohttp
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	var tests = []struct {
+		name     string
+		accept   string
+		codeWant string
+	}{
+		{"None", "", ""},
+		{"Gzip", "gzip", "gzip"},
+		{"Preference", "deflate, gzip", "gzip"},
+		{"QValues", "gzip;q=0.5, deflate;q=0.8", "deflate"},
+		{"Refused", "gzip;q=0", ""},
+		{"Wildcard", "*", "gzip"},
+		{"WildcardExcept", "*, gzip;q=0", "deflate"},
+		{"IdentityOnly", "identity", ""},
+		{"Unsupported", "br, zstd", ""},
+		{"CaseAndSpaces", " GZIP ; Q=1 ", "gzip"},
+		{"InvalidQ", "gzip;q=2, deflate", "deflate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.accept, "gzip", "deflate"); got != tt.codeWant {
+				t.Fatalf("encoding got: %q, want: %q", got, tt.codeWant)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("hello, world\n", 100)
+	short := long[:100]
+	c := &Compression{MinSize: 1024}
+
+	var tests = []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		text           string
+		encodingWant   string
+		varyWant       string
+	}{
+		{"Gzip", "gzip, deflate", "text/plain; charset=utf-8", long, "gzip", "Accept-Encoding"},
+		{"Deflate", "deflate", "application/json", long, "deflate", "Accept-Encoding"},
+		{"NotAccepted", "", "text/plain", long, "", "Accept-Encoding"},
+		{"NotCompressible", "gzip", "image/png", long, "", ""},
+		{"TooSmall", "gzip", "text/plain", short, "", "Accept-Encoding"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := tt.text
+			h := HandlerFunc(func(w ResponseWriter, req *Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(text)))
+				w.Header().Set("Etag", `"abc"`)
+				io.WriteString(w, text)
+			})
+			header := Header{}
+			if tt.acceptEncoding != "" {
+				header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: header, Body: NoBody}
+			w := newRecorder()
+			Compress(h, c).ServeGoHTTP(w, req)
+
+			if got := w.header.Get("Content-Encoding"); got != tt.encodingWant {
+				t.Fatalf("content encoding got: %q, want: %q", got, tt.encodingWant)
+			}
+			if got := w.header.Get("Vary"); got != tt.varyWant {
+				t.Fatalf("vary got: %q, want: %q", got, tt.varyWant)
+			}
+
+			var r io.Reader = &w.body
+			switch tt.encodingWant {
+			case "gzip":
+				zr, err := gzip.NewReader(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = zr
+			case "deflate":
+				zr, err := zlib.NewReader(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = zr
+			}
+			if tt.encodingWant != "" {
+				if w.header.Get("Etag") != `W/"abc"` {
+					t.Fatalf("etag got: %q, want a weak etag", w.header.Get("Etag"))
+				}
+				if w.body.Len() >= len(text) {
+					t.Fatalf("compressed body is %v bytes, not smaller than %v", w.body.Len(), len(text))
+				}
+			}
+			body, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != text {
+				t.Fatalf("body got: %v bytes, want: %v bytes", len(body), len(text))
+			}
+		})
+	}
+}
+
+func TestCompressServer(t *testing.T) {
+	s := &Server{
+		Addr:        ":0",
+		DocRoot:     "testdata",
+		Compression: &Compression{},
+	}
+	resText := roundTrip(t, s, "GET /index.html HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\nConnection: close\r\n\r\n")
+	for _, want := range []string{
+		"HTTP/1.1 200 OK\r\n",
+		"Content-Encoding: gzip\r\n",
+		"Vary: Accept-Encoding\r\n",
+	} {
+		if !strings.Contains(resText, want) {
+			t.Fatalf("response is missing %q\ngot: %q", want, resText)
+		}
+	}
+	for _, unwanted := range []string{"Content-Length: 12\r\n", "Accept-Ranges: "} {
+		if strings.Contains(resText, unwanted) {
+			t.Fatalf("response has unexpected %q\ngot: %q", unwanted, resText)
+		}
+	}
+}
+
+func TestCompressServerHead(t *testing.T) {
+	s := &Server{
+		Addr:        ":0",
+		DocRoot:     "testdata",
+		Compression: &Compression{},
+	}
+	headers := func(resText string) map[string]string {
+		head, _, _ := strings.Cut(resText, "\r\n\r\n")
+		fields := make(map[string]string)
+		for _, line := range strings.Split(head, "\r\n")[1:] {
+			k, v, _ := strings.Cut(line, ": ")
+			fields[k] = v
+		}
+		delete(fields, "Date")
+		return fields
+	}
+	reqText := " /index.html HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\nConnection: close\r\n\r\n"
+	getHeaders := headers(roundTrip(t, s, "GET"+reqText))
+	headText := roundTrip(t, s, "HEAD"+reqText)
+	if !strings.HasSuffix(headText, "\r\n\r\n") {
+		t.Fatalf("HEAD response got: %q, want no body", headText)
+	}
+	headHeaders := headers(headText)
+	if _, ok := headHeaders["Content-Length"]; ok {
+		t.Fatalf("HEAD response has a Content-Length, want none\ngot: %q", headText)
+	}
+	delete(getHeaders, "Content-Length")
+	if !reflect.DeepEqual(headHeaders, getHeaders) {
+		t.Fatalf("HEAD headers got: %v, want those of GET: %v", headHeaders, getHeaders)
+	}
+}
+
+func TestCompressServerVary(t *testing.T) {
+	s := &Server{
+		Addr:        ":0",
+		DocRoot:     "testdata",
+		Compression: &Compression{},
+	}
+
+	var tests = []struct {
+		name       string
+		url        string
+		header     string
+		statusWant int
+		varyWant   bool
+	}{
+		{"Compressed", "/index.html", "", 200, true},
+		{"NotModified", "/index.html", "If-None-Match: *\r\n", 304, true},
+		{"PartialContent", "/index.html", "Range: bytes=0-4\r\n", 206, true},
+		{"NotCompressible", "/fake.png", "", 200, false},
+		{"NotCompressibleNotModified", "/fake.png", "If-None-Match: *\r\n", 304, false},
+		{"NotCompressiblePartialContent", "/fake.png", "Range: bytes=0-4\r\n", 206, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resText := roundTrip(t, s, "GET "+tt.url+" HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\n"+
+				tt.header+"Connection: close\r\n\r\n")
+			if want := fmt.Sprintf("HTTP/1.1 %v ", tt.statusWant); !strings.HasPrefix(resText, want) {
+				t.Fatalf("response got: %.100q, want status %v", resText, tt.statusWant)
+			}
+			if got := strings.Contains(resText, "\r\nVary: Accept-Encoding\r\n"); got != tt.varyWant {
+				t.Fatalf("vary got: %v, want: %v\ngot: %q", got, tt.varyWant, resText)
+			}
+			if tt.statusWant == 304 && strings.Contains(resText, "\r\nContent-Type: ") {
+				t.Fatalf("304 response has a Content-Type\ngot: %q", resText)
+			}
+		})
+	}
+}
+
+func TestValidateServerSetupCompression(t *testing.T) {
+	for _, level := range []int{-1, 10} {
+		s := &Server{Addr: ":0", Handler: NotFoundHandler(), Compression: &Compression{Level: level}}
+		if err := s.ValidateServerSetup(); err == nil {
+			t.Fatalf("ValidateServerSetup got no error for level %v", level)
+		}
+	}
+	s := &Server{Addr: ":0", Handler: NotFoundHandler(), Compression: &Compression{Level: 9}}
+	if err := s.ValidateServerSetup(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+package gohttp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// fileETag returns the "ETag" of the file described by fi,
+// derived from its inode, size and modification time.
+func fileETag(fi os.FileInfo, weak bool) string {
+	etag := fmt.Sprintf(`"%x-%x-%x"`, fileInode(fi), fi.Size(), fi.ModTime().UnixNano())
+	if weak {
+		etag = "W/" + etag
+	}
+	return etag
+}
+
+// etagMatches reports whether etag matches any of the entity-tags in
+// list, the value of an "If-Match" or "If-None-Match" header.
+// The weak comparison ignores the "W/" prefix; the strong comparison
+// never matches a weak entity-tag (RFC 9110, Section 8.8.3.2).
+func etagMatches(list, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	etagWeak := strings.HasPrefix(etag, "W/")
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range splitETags(list) {
+		candidateWeak := strings.HasPrefix(candidate, "W/")
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate != etag {
+			continue
+		}
+		if weak || (!etagWeak && !candidateWeak) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitETags splits a comma-separated list of entity-tags.
+// Commas may appear inside the quotes of an entity-tag,
+// so the list can't simply be split on them.
+func splitETags(list string) []string {
+	var etags []string
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return etags
+		}
+		start := 0
+		if strings.HasPrefix(list, "W/") {
+			start = 2
+		}
+		if len(list) <= start || list[start] != '"' {
+			// Not an entity-tag; skip to the next element
+			i := strings.IndexByte(list, ',')
+			if i < 0 {
+				return etags
+			}
+			list = list[i:]
+			continue
+		}
+		end := strings.IndexByte(list[start+1:], '"')
+		if end < 0 {
+			return etags
+		}
+		end += start + 2
+		etags = append(etags, list[:end])
+		list = list[end:]
+	}
+}
+
+// modifiedSince reports whether res was modified after the time in the
+// header value s. A missing or invalid time counts as modified.
+func modifiedSince(res *Response, s string) bool {
+	t, err := ParseTime(s)
+	if err != nil {
+		return true
+	}
+	lastModified, err := ParseTime(res.Header.Get("Last-Modified"))
+	if err != nil {
+		return true
+	}
+	return lastModified.After(t.Truncate(time.Second))
+}
+
+// handleConditional evaluates the preconditions of req against res,
+// a 200 OK response for an existing file, in the order given by
+// RFC 9110, Section 13.2.2. If a precondition turns res into a
+// 304 Not Modified or 412 Precondition Failed response, it returns true.
+func (res *Response) handleConditional(req *Request) bool {
+	etag := res.Header.Get("Etag")
+	isGetOrHead := req.Method == "GET" || req.Method == "HEAD"
+
+	if req.Header.has("If-Match") {
+		if !etagMatches(req.Header.list("If-Match"), etag, false) {
+			res.handlePreconditionFailed()
+			return true
+		}
+	} else if req.Header.has("If-Unmodified-Since") {
+		ius := req.Header.Get("If-Unmodified-Since")
+		if _, err := ParseTime(ius); err == nil && modifiedSince(res, ius) {
+			res.handlePreconditionFailed()
+			return true
+		}
+	}
+
+	if req.Header.has("If-None-Match") {
+		if etagMatches(req.Header.list("If-None-Match"), etag, true) {
+			if isGetOrHead {
+				res.handleNotModified()
+			} else {
+				res.handlePreconditionFailed()
+			}
+			return true
+		}
+	} else if req.Header.has("If-Modified-Since") && isGetOrHead {
+		if !modifiedSince(res, req.Header.Get("If-Modified-Since")) {
+			res.handleNotModified()
+			return true
+		}
+	}
+	return false
+}
+
+// handleNotModified turns res into a 304 Not Modified response,
+// keeping the validators but dropping the body and its length.
+// The "Content-Type" is kept for middleware such as Compress to go
+// by, and left out when the response is sent.
+func (res *Response) handleNotModified() {
+	res.StatusCode = statusNotModified
+	res.FilePath = ""
+	res.Header.Del("Content-Length")
+}
+
+// handlePreconditionFailed turns res into a 412 Precondition Failed
+// response without a body.
+func (res *Response) handlePreconditionFailed() {
+	res.StatusCode = statusPreconditionFailed
+	res.FilePath = ""
+	res.Header.Set("Content-Length", "0")
+	res.Header.Del("Content-Type")
+}
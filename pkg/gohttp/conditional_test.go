@@ -0,0 +1,132 @@
+package gohttp
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	var tests = []struct {
+		name      string
+		list      string
+		etag      string
+		weak      bool
+		matchWant bool
+	}{
+		{"Any", "*", `"abc"`, false, true},
+		{"Strong", `"abc"`, `"abc"`, false, true},
+		{"List", `"xyz", "abc"`, `"abc"`, false, true},
+		{"CommaInTag", `"a,b", "c"`, `"a,b"`, false, true},
+		{"NoMatch", `"xyz"`, `"abc"`, true, false},
+		{"WeakCandidateWeakComparison", `W/"abc"`, `"abc"`, true, true},
+		{"WeakCandidateStrongComparison", `W/"abc"`, `"abc"`, false, false},
+		{"WeakETagStrongComparison", `"abc"`, `W/"abc"`, false, false},
+		{"Unquoted", `abc`, `"abc"`, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.list, tt.etag, tt.weak); got != tt.matchWant {
+				t.Fatalf("match got: %v, want: %v", got, tt.matchWant)
+			}
+		})
+	}
+}
+
+func TestHandleConditional(t *testing.T) {
+	s := &Server{Addr: ":0", DocRoot: "testdata"}
+	newRequest := func(method string, header Header) *Request {
+		return &Request{Method: method, URL: mustParseURL("/index.html"), Proto: "HTTP/1.1", Header: header, Body: NoBody}
+	}
+
+	fi, err := os.Stat("testdata/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := s.HandleGoodRequest(newRequest("GET", Header{})).Header.Get("Etag")
+	if etag != fileETag(fi, false) || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("etag got: %q, want: %q", etag, fileETag(fi, false))
+	}
+	lastModified := FormatTime(fi.ModTime())
+	before := "Mon, 02 Jan 2006 15:04:05 GMT"
+	after := "Fri, 01 Jan 2100 00:00:00 GMT"
+
+	var tests = []struct {
+		name       string
+		method     string
+		header     Header
+		statusWant int
+	}{
+		{"None", "GET", Header{}, 200},
+		{"IfNoneMatch", "GET", Header{"If-None-Match": {etag}}, 304},
+		{"IfNoneMatchWeak", "GET", Header{"If-None-Match": {"W/" + etag}}, 304},
+		{"IfNoneMatchHead", "HEAD", Header{"If-None-Match": {etag}}, 304},
+		{"IfNoneMatchOther", "GET", Header{"If-None-Match": {`"other"`}}, 200},
+		{"IfNoneMatchAny", "GET", Header{"If-None-Match": {"*"}}, 304},
+		{"IfModifiedSinceLastModified", "GET", Header{"If-Modified-Since": {lastModified}}, 304},
+		{"IfModifiedSinceBefore", "GET", Header{"If-Modified-Since": {before}}, 200},
+		{"IfModifiedSinceInvalid", "GET", Header{"If-Modified-Since": {"yesterday"}}, 200},
+		{
+			// If-None-Match takes precedence over If-Modified-Since
+			"IfNoneMatchOverIfModifiedSince",
+			"GET",
+			Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {lastModified}},
+			200,
+		},
+		{"IfMatch", "GET", Header{"If-Match": {etag}}, 200},
+		{"IfMatchOther", "GET", Header{"If-Match": {`"other"`}}, 412},
+		{"IfMatchWeak", "GET", Header{"If-Match": {"W/" + etag}}, 412},
+		{"IfUnmodifiedSinceAfter", "GET", Header{"If-Unmodified-Since": {after}}, 200},
+		{"IfUnmodifiedSinceBefore", "GET", Header{"If-Unmodified-Since": {before}}, 412},
+		{
+			// If-Match takes precedence over If-Unmodified-Since
+			"IfMatchOverIfUnmodifiedSince",
+			"GET",
+			Header{"If-Match": {etag}, "If-Unmodified-Since": {before}},
+			200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.HandleGoodRequest(newRequest(tt.method, tt.header))
+			if res.StatusCode != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", res.StatusCode, tt.statusWant)
+			}
+			if tt.statusWant == 200 {
+				return
+			}
+			if res.FilePath != "" {
+				t.Fatalf("file path got: %q, want no file to serve", res.FilePath)
+			}
+			if tt.statusWant == 304 {
+				if res.Header.has("Content-Length") {
+					t.Fatalf("unexpected header %q", "Content-Length")
+				}
+				if res.Header.Get("Etag") != etag {
+					t.Fatalf("etag got: %q, want: %q", res.Header.Get("Etag"), etag)
+				}
+			}
+		})
+	}
+}
+
+func TestWeakETags(t *testing.T) {
+	f := &FileHandler{Root: "testdata", WeakETags: true}
+	req := &Request{Method: "GET", URL: mustParseURL("/index.html"), Proto: "HTTP/1.1", Header: Header{}, Body: NoBody}
+	etag := f.prepare(req).Header.Get("Etag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("etag got: %q, want a weak etag", etag)
+	}
+
+	// A weak etag still works for caching, but not for If-Match
+	req.Header = Header{"If-None-Match": {etag}}
+	if res := f.prepare(req); res.StatusCode != 304 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 304)
+	}
+	req.Header = Header{"If-Match": {etag}}
+	if res := f.prepare(req); res.StatusCode != 412 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 412)
+	}
+}
@@ -0,0 +1,174 @@
+package gohttp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCookie is returned by Request.Cookie when the request
+// has no cookie with the name asked for.
+var ErrNoCookie = errors.New("gohttp: named cookie not present")
+
+// SameSite is the "SameSite" attribute of a cookie, which says
+// whether browsers send it with cross-site requests.
+type SameSite int
+
+const (
+	// SameSiteDefault leaves the attribute out, for browsers
+	// to apply their default, usually Lax.
+	SameSiteDefault SameSite = iota
+	SameSiteLax
+	SameSiteStrict
+
+	// SameSiteNone sends the cookie with cross-site requests.
+	// Browsers require Secure along with it.
+	SameSiteNone
+)
+
+// A Cookie is an HTTP cookie, as received in the "Cookie" header of a
+// request or sent in a "Set-Cookie" header of a response (RFC 6265).
+// Only Name and Value are set for a cookie received.
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path   string // e.g. "/"; if empty, the path of the request
+	Domain string // e.g. "example.com"; if empty, the host of the request
+
+	// Expires is when the cookie expires. If zero, it is left out.
+	Expires time.Time
+
+	// MaxAge is how many seconds the cookie lives for, taking
+	// precedence over Expires. Zero leaves it out, and a negative
+	// value deletes the cookie right away, as "Max-Age=0".
+	MaxAge int
+
+	Secure   bool // only send the cookie over HTTPS
+	HttpOnly bool // keep the cookie from scripts
+	SameSite SameSite
+
+	// Partitioned keeps the cookie to the top-level site it is set
+	// under, when embedded in another. Browsers require Secure
+	// along with it.
+	Partitioned bool
+}
+
+// String returns the value of the "Set-Cookie" header for c, or ""
+// if c.Name is not a valid cookie name. Bytes not allowed in a cookie
+// value are dropped, and so are attributes with invalid values.
+func (c *Cookie) String() string {
+	if !validCookieName(c.Name) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	value := strings.Map(func(r rune) rune {
+		if r == ' ' || r == ',' || isCookieOctet(r) {
+			return r
+		}
+		return -1
+	}, c.Value)
+	if strings.ContainsAny(value, " ,") {
+		// Quoted, as some browsers would otherwise cut the value there
+		value = `"` + value + `"`
+	}
+	b.WriteString(value)
+
+	if c.Path != "" && validCookieAttribute(c.Path) {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" && validCookieAttribute(c.Domain) {
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + FormatTime(c.Expires))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	switch c.SameSite {
+	case SameSiteLax:
+		b.WriteString("; SameSite=Lax")
+	case SameSiteStrict:
+		b.WriteString("; SameSite=Strict")
+	case SameSiteNone:
+		b.WriteString("; SameSite=None")
+	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+	return b.String()
+}
+
+// SetCookie adds a "Set-Cookie" header for c to the response of w.
+// Each cookie set gets a header of its own. Invalid cookies are
+// left out.
+func SetCookie(w ResponseWriter, c *Cookie) {
+	if v := c.String(); v != "" {
+		w.Header().Add("Set-Cookie", v)
+	}
+}
+
+// Cookies returns the cookies sent with req, in the order sent.
+// Malformed cookies are skipped.
+func (req *Request) Cookies() []*Cookie {
+	var cookies []*Cookie
+	for _, line := range req.Header.Values("Cookie") {
+		for _, pair := range strings.Split(line, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !validCookieName(name) {
+				continue
+			}
+			quoted := len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"'
+			if quoted {
+				value = value[1 : len(value)-1]
+			}
+			if strings.IndexFunc(value, func(r rune) bool {
+				return !isCookieOctet(r) && !(quoted && (r == ' ' || r == ','))
+			}) != -1 {
+				continue
+			}
+			cookies = append(cookies, &Cookie{Name: name, Value: value})
+		}
+	}
+	return cookies
+}
+
+// Cookie returns the first cookie named name sent with req,
+// or ErrNoCookie if there is none.
+func (req *Request) Cookie(name string) (*Cookie, error) {
+	for _, c := range req.Cookies() {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, ErrNoCookie
+}
+
+func validCookieName(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool { return !isTokenChar(r) }) == -1
+}
+
+// isCookieOctet reports whether r may appear in a cookie value,
+// which excludes controls, whitespace, DQUOTE, comma, semicolon
+// and backslash (RFC 6265, Section 4.1.1).
+func isCookieOctet(r rune) bool {
+	return r >= 0x21 && r <= 0x7e && r != '"' && r != ',' && r != ';' && r != '\\'
+}
+
+// validCookieAttribute reports whether v can be the value
+// of a "Path" or "Domain" attribute.
+func validCookieAttribute(v string) bool {
+	return strings.IndexFunc(v, func(r rune) bool { return r < 0x20 || r >= 0x7f || r == ';' }) == -1
+}
@@ -0,0 +1,115 @@
+package gohttp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCookieString(t *testing.T) {
+	var tests = []struct {
+		name   string
+		cookie Cookie
+		want   string
+	}{
+		{"Plain", Cookie{Name: "a", Value: "1"}, "a=1"},
+		{"Empty", Cookie{Name: "a"}, "a="},
+		{
+			"AllAttributes",
+			Cookie{
+				Name:        "id",
+				Value:       "xyz",
+				Path:        "/app",
+				Domain:      ".example.com",
+				Expires:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				MaxAge:      3600,
+				Secure:      true,
+				HttpOnly:    true,
+				SameSite:    SameSiteNone,
+				Partitioned: true,
+			},
+			"id=xyz; Path=/app; Domain=example.com; Expires=Fri, 02 Jan 2026 03:04:05 GMT; Max-Age=3600; HttpOnly; Secure; SameSite=None; Partitioned",
+		},
+		{"Lax", Cookie{Name: "a", Value: "1", SameSite: SameSiteLax}, "a=1; SameSite=Lax"},
+		{"Strict", Cookie{Name: "a", Value: "1", SameSite: SameSiteStrict}, "a=1; SameSite=Strict"},
+		{"Delete", Cookie{Name: "a", MaxAge: -1}, "a=; Max-Age=0"},
+		{"QuotedValue", Cookie{Name: "a", Value: "x y,z"}, `a="x y,z"`},
+		{"InvalidValueBytes", Cookie{Name: "a", Value: "x;y\"z\\"}, "a=xyz"},
+		{"InvalidPath", Cookie{Name: "a", Value: "1", Path: "/;x"}, "a=1"},
+		{"InvalidName", Cookie{Name: "a b", Value: "1"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cookie.String(); got != tt.want {
+				t.Fatalf("got: %q, want: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	w := newRecorder()
+	SetCookie(w, &Cookie{Name: "a", Value: "1"})
+	SetCookie(w, &Cookie{Name: "b", Value: "2", HttpOnly: true})
+	SetCookie(w, &Cookie{Name: "", Value: "invalid"})
+	want := []string{"a=1", "b=2; HttpOnly"}
+	if got := w.header.Values("Set-Cookie"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Set-Cookie got: %q, want: %q", got, want)
+	}
+}
+
+func TestRequestCookies(t *testing.T) {
+	var tests = []struct {
+		name    string
+		headers []string
+		want    []*Cookie
+	}{
+		{"None", nil, nil},
+		{"One", []string{"a=1"}, []*Cookie{{Name: "a", Value: "1"}}},
+		{
+			"Several",
+			[]string{"a=1; b=2;c=3"},
+			[]*Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "c", Value: "3"}},
+		},
+		{
+			"RepeatedHeader",
+			[]string{"a=1", "b=2"},
+			[]*Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		},
+		{"Quoted", []string{`a="x y"`}, []*Cookie{{Name: "a", Value: "x y"}}},
+		{"EmptyValue", []string{"a="}, []*Cookie{{Name: "a", Value: ""}}},
+		{
+			"SkipsMalformed",
+			[]string{`novalue; a b=1; c=x y; d="e; f=2`},
+			[]*Cookie{{Name: "f", Value: "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Header: Header{}}
+			for _, h := range tt.headers {
+				req.Header.Add("Cookie", h)
+			}
+			if got := req.Cookies(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestCookie(t *testing.T) {
+	req := &Request{Header: Header{"Cookie": {"a=1; b=2; a=3"}}}
+	c, err := req.Cookie("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Value != "1" {
+		t.Fatalf("value got: %q, want: %q", c.Value, "1")
+	}
+	if _, err := req.Cookie("missing"); !errors.Is(err, ErrNoCookie) {
+		t.Fatalf("error got: %v, want: %v", err, ErrNoCookie)
+	}
+}
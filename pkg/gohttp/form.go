@@ -0,0 +1,148 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+)
+
+const (
+	// maxFormBytes is the limit on the size of an
+	// application/x-www-form-urlencoded request body.
+	maxFormBytes = 10 << 20
+
+	// defaultMaxMemory is how many bytes of a multipart/form-data
+	// request body FormValue and FormFile keep in memory.
+	defaultMaxMemory = 32 << 20
+)
+
+var (
+	// ErrNotMultipart is returned by ParseMultipartForm when the
+	// request body is not multipart/form-data.
+	ErrNotMultipart = errors.New("gohttp: request Content-Type isn't multipart/form-data")
+
+	// ErrMissingFile is returned by FormFile when the form
+	// has no file in the field.
+	ErrMissingFile = errors.New("gohttp: no such file")
+)
+
+// ParseForm fills req.Form and req.PostForm.
+//
+// For POST, PUT and PATCH requests with an
+// application/x-www-form-urlencoded body, it reads the body,
+// up to 10MB, and parses it into req.PostForm. req.Form holds the
+// values of both the body and the query string, those of the body
+// first. Other requests get an empty req.PostForm.
+//
+// ParseMultipartForm calls ParseForm, and calling it again does nothing.
+func (req *Request) ParseForm() error {
+	if req.Form != nil {
+		return nil
+	}
+	var err error
+	req.PostForm = make(url.Values)
+	if req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH" {
+		err = req.parsePostForm()
+	}
+	req.Form = make(url.Values)
+	for k, v := range req.PostForm {
+		req.Form[k] = append(req.Form[k], v...)
+	}
+	query, queryErr := url.ParseQuery(req.URL.RawQuery)
+	for k, v := range query {
+		req.Form[k] = append(req.Form[k], v...)
+	}
+	if err == nil && queryErr != nil {
+		err = &statusError{statusBadRequest, fmt.Sprintf("invalid query string: %v", queryErr)}
+	}
+	return err
+}
+
+// parsePostForm parses an application/x-www-form-urlencoded body
+// into req.PostForm. Bodies of other types are left alone.
+func (req *Request) parsePostForm() error {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return nil
+	}
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxFormBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(b) > maxFormBytes {
+		return &statusError{statusContentTooLarge, "form body too large"}
+	}
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return &statusError{statusBadRequest, fmt.Sprintf("invalid form body: %v", err)}
+	}
+	req.PostForm = values
+	return nil
+}
+
+// ParseMultipartForm parses a multipart/form-data request body into
+// req.MultipartForm, after calling ParseForm. The values of the form
+// are also added to req.PostForm and req.Form, before those of the
+// query string. At most maxMemory bytes of file parts are kept in
+// memory; the rest are stored in temporary files, which the server
+// removes once the handler returns.
+//
+// It returns ErrNotMultipart if the body is of another type.
+// Calling it again does nothing.
+func (req *Request) ParseMultipartForm(maxMemory int64) error {
+	if req.MultipartForm != nil {
+		return nil
+	}
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return ErrNotMultipart
+	}
+	form, err := multipart.NewReader(req.Body, params["boundary"]).ReadForm(maxMemory)
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		return &statusError{statusContentTooLarge, "multipart form too large"}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid multipart form: %w", err)
+	}
+	for k, v := range form.Value {
+		req.PostForm[k] = append(req.PostForm[k], v...)
+		req.Form[k] = append(append([]string(nil), v...), req.Form[k]...)
+	}
+	req.MultipartForm = form
+	return nil
+}
+
+// FormValue returns the first value of key in the form of req,
+// parsing the form first if needed. Parsing errors are ignored;
+// call ParseMultipartForm to check them.
+func (req *Request) FormValue(key string) string {
+	if req.MultipartForm == nil {
+		req.ParseMultipartForm(defaultMaxMemory)
+	}
+	return req.Form.Get(key)
+}
+
+// FormFile returns the first file of key in the multipart form
+// of req, parsing the form first if needed.
+func (req *Request) FormFile(key string) (multipart.File, *multipart.FileHeader, error) {
+	if req.MultipartForm == nil {
+		if err := req.ParseMultipartForm(defaultMaxMemory); err != nil {
+			return nil, nil, err
+		}
+	}
+	files := req.MultipartForm.File[key]
+	if len(files) == 0 {
+		return nil, nil, ErrMissingFile
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, files[0], nil
+}
@@ -0,0 +1,216 @@
+package gohttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// multipartBody returns a multipart/form-data body with the given
+// values, and a file part per name in files, and its Content-Type.
+func multipartBody(t *testing.T, values map[string]string, field string, files map[string]string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range values {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), mw.FormDataContentType()
+}
+
+func TestParseForm(t *testing.T) {
+	var tests = []struct {
+		name         string
+		method       string
+		target       string
+		contentType  string
+		body         string
+		formWant     url.Values
+		postFormWant url.Values
+		statusWant   int // 0 for no error
+	}{
+		{
+			"Query",
+			"GET",
+			"/?a=1&b=2",
+			"",
+			"",
+			url.Values{"a": {"1"}, "b": {"2"}},
+			url.Values{},
+			0,
+		},
+		{
+			"URLEncoded",
+			"POST",
+			"/?a=query",
+			"application/x-www-form-urlencoded",
+			"a=body&c=x+y",
+			url.Values{"a": {"body", "query"}, "c": {"x y"}},
+			url.Values{"a": {"body"}, "c": {"x y"}},
+			0,
+		},
+		{
+			"URLEncodedWithCharset",
+			"PUT",
+			"/",
+			"application/x-www-form-urlencoded; charset=utf-8",
+			"a=1",
+			url.Values{"a": {"1"}},
+			url.Values{"a": {"1"}},
+			0,
+		},
+		{
+			"OtherType",
+			"POST",
+			"/?a=1",
+			"application/json",
+			`{"a": 2}`,
+			url.Values{"a": {"1"}},
+			url.Values{},
+			0,
+		},
+		{
+			"BodyIgnoredForGet",
+			"GET",
+			"/",
+			"application/x-www-form-urlencoded",
+			"a=1",
+			url.Values{},
+			url.Values{},
+			0,
+		},
+		{
+			"InvalidBody",
+			"POST",
+			"/",
+			"application/x-www-form-urlencoded",
+			"a=%zz",
+			url.Values{},
+			url.Values{},
+			400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := writeRequest(tt.method, tt.target, tt.body, false)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			err := req.ParseForm()
+			if tt.statusWant == 0 && err != nil {
+				t.Fatal(err)
+			}
+			if tt.statusWant != 0 {
+				if err == nil || errorStatus(err) != tt.statusWant {
+					t.Fatalf("error got: %v, want one with status %v", err, tt.statusWant)
+				}
+				return
+			}
+			if !reflect.DeepEqual(req.Form, tt.formWant) {
+				t.Fatalf("Form got: %v, want: %v", req.Form, tt.formWant)
+			}
+			if !reflect.DeepEqual(req.PostForm, tt.postFormWant) {
+				t.Fatalf("PostForm got: %v, want: %v", req.PostForm, tt.postFormWant)
+			}
+		})
+	}
+}
+
+func TestParseMultipartForm(t *testing.T) {
+	body, contentType := multipartBody(t, map[string]string{"a": "body"}, "file", map[string]string{
+		"big.txt": strings.Repeat("x", 1024),
+	})
+	req := writeRequest("POST", "/?a=query", body, false)
+	req.Header.Set("Content-Type", contentType)
+	// Keep less than the file in memory, so that it is spilled to disk
+	if err := req.ParseMultipartForm(100); err != nil {
+		t.Fatal(err)
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	formWant := url.Values{"a": {"body", "query"}}
+	if !reflect.DeepEqual(req.Form, formWant) {
+		t.Fatalf("Form got: %v, want: %v", req.Form, formWant)
+	}
+	if got := req.PostForm.Get("a"); got != "body" {
+		t.Fatalf("PostForm a got: %q, want: %q", got, "body")
+	}
+	f, fh, err := req.FormFile("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, ok := f.(*os.File); !ok {
+		t.Fatalf("file got: %T, want a temporary *os.File", f)
+	}
+	if fh.Filename != "big.txt" || fh.Size != 1024 {
+		t.Fatalf("file header got: %q %v, want: %q %v", fh.Filename, fh.Size, "big.txt", 1024)
+	}
+	if _, _, err := req.FormFile("missing"); !errors.Is(err, ErrMissingFile) {
+		t.Fatalf("FormFile error got: %v, want: %v", err, ErrMissingFile)
+	}
+}
+
+func TestParseMultipartFormNotMultipart(t *testing.T) {
+	req := writeRequest("POST", "/", "a=1", false)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.ParseMultipartForm(defaultMaxMemory); !errors.Is(err, ErrNotMultipart) {
+		t.Fatalf("error got: %v, want: %v", err, ErrNotMultipart)
+	}
+	if got := req.FormValue("a"); got != "1" {
+		t.Fatalf("FormValue got: %q, want: %q", got, "1")
+	}
+}
+
+func TestServerRemovesMultipartFiles(t *testing.T) {
+	var tmpPath string
+	s := &Server{Addr: ":0", Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+		if err := req.ParseMultipartForm(100); err != nil {
+			t.Error(err)
+			return
+		}
+		f, _, err := req.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if osFile, ok := f.(*os.File); ok {
+			tmpPath = osFile.Name()
+		}
+		f.Close()
+	})}
+	body, contentType := multipartBody(t, nil, "file", map[string]string{
+		"big.txt": strings.Repeat("x", 1024),
+	})
+	reqText := "POST / HTTP/1.1\r\nHost: test\r\nConnection: close\r\n" +
+		"Content-Type: " + contentType + "\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	if res := roundTrip(t, s, reqText); !strings.HasPrefix(res, "HTTP/1.1 200 OK\r\n") {
+		t.Fatalf("response got: %.100q, want status 200", res)
+	}
+	if tmpPath == "" {
+		t.Fatal("file got kept in memory, want a temporary file")
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Fatalf("temporary file %v stat error got: %v, want it removed", tmpPath, err)
+	}
+}
@@ -0,0 +1,231 @@
+package gohttp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileServer returns a handler that serves requests with the
+// contents of the file system rooted at root, using the default
+// FileHandler settings.
+func FileServer(root string) Handler {
+	return &FileHandler{Root: root}
+}
+
+// FileHandler is a handler serving static files. A request for a
+// directory ending in "/" is served with the directory's index.html.
+//
+// If a file has precompressed siblings, e.g. "style.css.gz" next to
+// "style.css", the sibling is served to clients accepting its content
+// coding. Brotli (".br"), Zstandard (".zst") and gzip (".gz") siblings
+// are supported.
+//
+// Directories listed in Writable also accept PUT, which atomically
+// creates or replaces a file, and DELETE, which removes one.
+type FileHandler struct {
+	// Root specifies the path to the directory to serve files from.
+	Root string
+
+	// WeakETags makes the "ETag" of each file a weak validator.
+	// By default, ETags are strong, so they can be used with
+	// "If-Match" and "If-Range".
+	WeakETags bool
+
+	// AutoIndex lists the contents of directories without an
+	// index.html, instead of answering 404 Not Found. A directory
+	// opts out with a ".noautoindex" file, or opts in with an
+	// ".autoindex" file when AutoIndex is off.
+	AutoIndex bool
+
+	// ShowHidden serves files whose names start with ".", and
+	// includes them in directory listings. Otherwise, they are
+	// answered with 404 Not Found, except for ".well-known".
+	ShowHidden bool
+
+	// Hide lists patterns, as in filepath.Match, of file names never
+	// to serve or list, e.g. "*.bak". Editor backup files ending in
+	// "~" and swap files ending in ".swp" are always hidden.
+	Hide []string
+
+	// Symlinks says whether symbolic links are followed. They never
+	// lead outside Root, nor to files hidden by the settings above.
+	Symlinks SymlinkPolicy
+
+	// Writable lists directories, as URL paths such as "/artifacts",
+	// whose files can be created or replaced with PUT and removed
+	// with DELETE. By default, all files are read-only.
+	Writable []string
+
+	// MaxUploadBytes limits the size of the body of a PUT request.
+	// Larger uploads get 413 Content Too Large. If zero,
+	// defaultMaxUploadBytes is used.
+	MaxUploadBytes int64
+
+	// mu serializes the checks of preconditions and the changes
+	// of files made by PUT and DELETE.
+	mu sync.Mutex
+
+	rootMu sync.Mutex
+	osRoot *sharedRoot // the root last opened by acquireRoot
+}
+
+var (
+	// fileMethods are the methods a FileHandler supports.
+	fileMethods = allowedMethods("GET")
+
+	// writableFileMethods are the methods a FileHandler supports
+	// under its Writable directories.
+	writableFileMethods = allowedMethods("GET", "PUT", "DELETE")
+)
+
+func (f *FileHandler) ServeGoHTTP(w ResponseWriter, req *Request) {
+	methods := fileMethods
+	if name, err := cleanPath(req.URL.Path); err == nil && f.writableDir(name) != "" {
+		methods = writableFileMethods
+	}
+	if !checkMethod(w, req, methods) {
+		return
+	}
+	root, err := f.acquireRoot()
+	if err != nil {
+		req.logger().Warn("failed to open root", "root", f.Root, "err", err)
+		NotFoundHandler().ServeGoHTTP(w, req)
+		return
+	}
+	// The root stays open until the response is sent
+	defer f.releaseRoot(root)
+	var res *Response
+	switch req.Method {
+	case "PUT":
+		res = f.put(req, root.Root)
+	case "DELETE":
+		res = f.delete(req, root.Root)
+	default:
+		res = f.prepareIn(req, root.Root)
+	}
+	if err := res.Send(w); err != nil {
+		req.logger().Warn("failed to send file", "path", req.URL.Path, "err", err)
+	}
+}
+
+func (f *FileHandler) methods() []string {
+	if len(f.Writable) > 0 {
+		return writableFileMethods
+	}
+	return fileMethods
+}
+
+// prepare resolves req to a file under f.Root and
+// generates the corresponding res.
+func (f *FileHandler) prepare(req *Request) *Response {
+	root, err := f.acquireRoot()
+	if err != nil {
+		req.logger().Warn("failed to open root", "root", f.Root, "err", err)
+		res := &Response{Header: make(Header), Request: req}
+		res.HandleNotFound(req)
+		return res
+	}
+	defer f.releaseRoot(root)
+	return f.prepareIn(req, root.Root)
+}
+
+// prepareIn is like prepare, with f.Root opened as r, which must stay
+// open until res is sent. Files are looked up through r, so that
+// a symbolic link changed since it was resolved can't lead outside.
+func (f *FileHandler) prepareIn(req *Request, r *os.Root) (res *Response) {
+	res = &Response{
+		Header:  make(Header),
+		Request: req,
+	}
+	res.Proto = responseProto
+	res.StatusCode = statusOK
+	urlPath, rawQuery := req.URL.Path, req.URL.RawQuery
+	name, err := cleanPath(urlPath)
+	if err != nil {
+		res.HandleError(statusBadRequest)
+		return res
+	}
+
+	// Handle for 404 response (a valid request is received, and the requested file cannot be found or is not under the doc root.)
+	root := r.Name()
+	res.FilePath, err = f.resolve(root, name)
+	if err != nil {
+		req.logger().Debug("file not served", "path", name, "err", err)
+		res.FilePath = ""
+		res.HandleNotFound(req)
+		return res
+	}
+	dirPath := ""
+
+	// Check if file exist
+	fi, err := statIn(r, res.FilePath)
+	if err != nil {
+		req.logger().Debug("file not found", "path", res.FilePath, "err", err)
+		res.FilePath = ""
+		res.HandleNotFound(req)
+		return res
+		// Check if it's a folder, if so with /, add index.html, if not , return file not found
+	} else if fi.IsDir() {
+		req.logger().Debug("file is a directory", "path", res.FilePath)
+		if strings.HasSuffix(urlPath, "/") {
+			dirPath = res.FilePath
+			if res.FilePath, err = f.follow(root, filepath.Join(dirPath, "index.html")); err != nil {
+				res.FilePath = ""
+				res.HandleNotFound(req)
+				return res
+			}
+		} else {
+			// Send the client to the directory URL, so that relative
+			// links in its index resolve under it. The location is built
+			// from the clean path, as "//host" would leave the site.
+			location := (&url.URL{Path: name + "/"}).EscapedPath()
+			res.HandleRedirect(req, withQuery(location, rawQuery), statusMovedPermanently)
+			return res
+		}
+	}
+
+	if _, err := statIn(r, res.FilePath); err != nil {
+		res.FilePath = ""
+		if dirPath != "" && f.autoIndexes(r, dirPath) {
+			f.listDirectory(res, r, dirPath, urlPath, rawQuery)
+			return res
+		}
+		res.HandleNotFound(req)
+		return res
+	}
+	// Serve a precompressed sibling of the file instead, if the client accepts one.
+	// The type is still the one of the original file.
+	contentType := MIMETypeByExtension(filepath.Ext(res.FilePath))
+	servePath, coding, vary := findPrecompressed(r, res.FilePath, req.Header.list("Accept-Encoding"))
+	if coding != "" {
+		// The sibling is subject to the same rules as the file
+		if p, err := f.follow(root, servePath); err != nil || p != servePath {
+			servePath, coding = res.FilePath, ""
+		}
+	}
+
+	// HandleOk
+	res.root = r
+	res.HandleOK(req, servePath)
+	if res.StatusCode != statusOK {
+		return res
+	}
+	if coding != "" {
+		res.Header.Set("Content-Type", contentType)
+		res.Header.Set("Content-Encoding", coding)
+	}
+	if vary {
+		addVary(res.Header, "Accept-Encoding")
+	}
+	if fi, err := res.statFile(); err == nil {
+		res.Header.Set("Etag", fileETag(fi, f.WeakETags))
+	}
+	if res.handleConditional(req) {
+		return res
+	}
+	res.handleRange(req)
+	return res
+}
@@ -0,0 +1,278 @@
+package gohttp
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Handler responds to a valid request.
+//
+// ServeGoHTTP should write the response headers and body to w and then
+// return. The response is complete once ServeGoHTTP returns.
+type Handler interface {
+	ServeGoHTTP(w ResponseWriter, req *Request)
+}
+
+// The HandlerFunc type is an adapter to allow the use of
+// ordinary functions as handlers.
+type HandlerFunc func(w ResponseWriter, req *Request)
+
+// ServeGoHTTP calls f(w, req).
+func (f HandlerFunc) ServeGoHTTP(w ResponseWriter, req *Request) {
+	f(w, req)
+}
+
+// NotFound replies to req with a 404 Not Found response.
+func NotFound(w ResponseWriter, req *Request) {
+	res := &Response{
+		Header: make(Header),
+	}
+	res.HandleNotFound(req)
+	res.Send(w)
+}
+
+// NotFoundHandler returns a handler that replies to each request
+// with a 404 Not Found response.
+func NotFoundHandler() Handler {
+	return HandlerFunc(NotFound)
+}
+
+// MethodNotAllowed replies to req with a 405 Method Not Allowed
+// response, listing the methods the resource supports in "Allow".
+func MethodNotAllowed(w ResponseWriter, req *Request, allow ...string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	w.WriteHeader(statusMethodNotAllowed)
+}
+
+// Methods returns a handler that only passes requests using one of the
+// given methods on to h. HEAD is allowed whenever GET is, and OPTIONS
+// requests are answered directly with the "Allow" header. Requests
+// using any other method get 405 Method Not Allowed.
+func Methods(h Handler, methods ...string) Handler {
+	return &methodHandler{
+		handler: h,
+		allow:   allowedMethods(methods...),
+	}
+}
+
+type methodHandler struct {
+	handler Handler
+	allow   []string
+}
+
+func (m *methodHandler) ServeGoHTTP(w ResponseWriter, req *Request) {
+	if checkMethod(w, req, m.allow) {
+		m.handler.ServeGoHTTP(w, req)
+	}
+}
+
+func (m *methodHandler) methods() []string {
+	return m.allow
+}
+
+// allowedMethods completes methods with HEAD, if GET is present,
+// and OPTIONS.
+func allowedMethods(methods ...string) []string {
+	allow := make([]string, 0, len(methods)+2)
+	seen := make(map[string]bool)
+	add := func(m string) {
+		if !seen[m] {
+			seen[m] = true
+			allow = append(allow, m)
+		}
+	}
+	for _, m := range methods {
+		add(m)
+		if m == "GET" {
+			add("HEAD")
+		}
+	}
+	add("OPTIONS")
+	return allow
+}
+
+// defaultMethods are the methods a handler is assumed to support,
+// unless it tells otherwise.
+var defaultMethods = allowedMethods("GET")
+
+// A methodLister is a handler telling the methods it supports,
+// as listed in the response to "OPTIONS *".
+type methodLister interface {
+	methods() []string
+}
+
+// handlerMethods returns the methods supported by any of handlers,
+// completed as by allowedMethods.
+func handlerMethods(handlers ...Handler) []string {
+	var methods []string
+	for _, h := range handlers {
+		if ml, ok := h.(methodLister); ok {
+			methods = append(methods, ml.methods()...)
+		} else {
+			methods = append(methods, defaultMethods...)
+		}
+	}
+	// OPTIONS goes last, and the others in a stable order
+	methods = slices.DeleteFunc(methods, func(m string) bool { return m == "OPTIONS" })
+	sort.Strings(methods)
+	return allowedMethods(methods...)
+}
+
+// checkMethod answers OPTIONS requests and requests using a method
+// not in allow on behalf of a resource. It reports whether req
+// is left for the resource to serve.
+func checkMethod(w ResponseWriter, req *Request, allow []string) bool {
+	if req.Method == "OPTIONS" {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(statusOK)
+		return false
+	}
+	for _, m := range allow {
+		if m == req.Method {
+			return true
+		}
+	}
+	MethodNotAllowed(w, req, allow...)
+	return false
+}
+
+// ServeMux is a request router. It matches the URL of each request
+// against a list of registered patterns and calls the handler for
+// the pattern that most closely matches the URL.
+//
+// A pattern not ending in a slash names a fixed path, e.g. "/status",
+// and only matches that exact path. A pattern ending in a slash names
+// a rooted subtree, e.g. "/static/", and matches any path with that
+// prefix. Exact patterns take precedence over subtree patterns, and
+// longer subtree patterns take precedence over shorter ones, so "/"
+// can be used to catch everything not matched elsewhere.
+type ServeMux struct {
+	mu       sync.RWMutex
+	exact    map[string]Handler
+	prefixes []muxEntry // sorted from longest to shortest pattern
+}
+
+type muxEntry struct {
+	pattern string
+	handler Handler
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		exact: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for the given pattern.
+// It panics if the pattern is invalid or already registered.
+func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	if pattern == "" || pattern[0] != '/' {
+		panic("gohttp: invalid pattern " + pattern)
+	}
+	if handler == nil {
+		panic("gohttp: nil handler for pattern " + pattern)
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.exact == nil {
+		mux.exact = make(map[string]Handler)
+	}
+	if !strings.HasSuffix(pattern, "/") {
+		if _, ok := mux.exact[pattern]; ok {
+			panic("gohttp: multiple registrations for " + pattern)
+		}
+		mux.exact[pattern] = handler
+		return
+	}
+	for _, e := range mux.prefixes {
+		if e.pattern == pattern {
+			panic("gohttp: multiple registrations for " + pattern)
+		}
+	}
+	mux.prefixes = append(mux.prefixes, muxEntry{pattern: pattern, handler: handler})
+	sort.SliceStable(mux.prefixes, func(i, j int) bool {
+		return len(mux.prefixes[i].pattern) > len(mux.prefixes[j].pattern)
+	})
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (mux *ServeMux) HandleFunc(pattern string, handler func(w ResponseWriter, req *Request)) {
+	mux.Handle(pattern, HandlerFunc(handler))
+}
+
+// Handler returns the handler to use for req, and the pattern it was
+// registered with. If no pattern matches, it returns a handler replying
+// with 404 Not Found and an empty pattern.
+//
+// The path of req is matched once cleaned of "." and ".." elements and
+// repeated slashes, so that "/pub/../private/" can't get around the
+// handler of "/private/". If the path isn't clean, the handler returned
+// redirects to the clean path with 301 Moved Permanently.
+func (mux *ServeMux) Handler(req *Request) (h Handler, pattern string) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	p := canonicalPath(req.URL.Path)
+	h, pattern = mux.match(p)
+	if p != req.URL.Path {
+		location := withQuery((&url.URL{Path: p}).EscapedPath(), req.URL.RawQuery)
+		return HandlerFunc(func(w ResponseWriter, req *Request) {
+			Redirect(w, req, location, statusMovedPermanently)
+		}), pattern
+	}
+	return h, pattern
+}
+
+func (mux *ServeMux) methods() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	handlers := make([]Handler, 0, len(mux.exact)+len(mux.prefixes))
+	for _, h := range mux.exact {
+		handlers = append(handlers, h)
+	}
+	for _, e := range mux.prefixes {
+		handlers = append(handlers, e.handler)
+	}
+	return handlerMethods(handlers...)
+}
+
+// match returns the handler and pattern matching the clean path p.
+func (mux *ServeMux) match(p string) (Handler, string) {
+	if h, ok := mux.exact[p]; ok {
+		return h, p
+	}
+	for _, e := range mux.prefixes {
+		if strings.HasPrefix(p, e.pattern) {
+			return e.handler, e.pattern
+		}
+	}
+	return NotFoundHandler(), ""
+}
+
+// canonicalPath returns p, a decoded URL path, rooted and cleaned
+// with path.Clean, but keeping its trailing slash, if any.
+func canonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	np := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// ServeGoHTTP dispatches req to the handler whose pattern
+// most closely matches the request URL.
+func (mux *ServeMux) ServeGoHTTP(w ResponseWriter, req *Request) {
+	h, _ := mux.Handler(req)
+	h.ServeGoHTTP(w, req)
+}
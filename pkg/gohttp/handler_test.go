@@ -0,0 +1,146 @@
+package gohttp
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+)
+
+func TestServeMux(t *testing.T) {
+	named := func(name string) Handler {
+		return HandlerFunc(func(w ResponseWriter, req *Request) {
+			w.Header().Set("Handler", name)
+		})
+	}
+	mux := NewServeMux()
+	mux.Handle("/", named("root"))
+	mux.Handle("/api/", named("api"))
+	mux.Handle("/api/status", named("status"))
+	mux.Handle("/api/v2/", named("v2"))
+	mux.Handle("/private/", named("private"))
+
+	var tests = []struct {
+		name         string
+		url          string
+		handlerWant  string
+		patternWant  string
+		locationWant string // "" if not redirected
+	}{
+		{"Root", "/", "root", "/", ""},
+		{"CatchAll", "/index.html", "root", "/", ""},
+		{"Prefix", "/api/users", "api", "/api/", ""},
+		{"Exact", "/api/status", "status", "/api/status", ""},
+		{"ExactIsNotPrefix", "/api/status/more", "api", "/api/", ""},
+		{"LongestPrefix", "/api/v2/users", "v2", "/api/v2/", ""},
+		{"PrefixWithoutSlash", "/api", "root", "/", ""},
+		{"DotDot", "/pub/../private/secret.txt", "", "/private/", "/private/secret.txt"},
+		{"EncodedDotDot", "/%2e%2e/private/secret.txt", "", "/private/", "/private/secret.txt"},
+		{"DoubleSlash", "//private/secret.txt", "", "/private/", "/private/secret.txt"},
+		{"TrailingSlashKept", "/api/./v2/?q=1", "", "/api/v2/", "/api/v2/?q=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: "GET", URL: mustParseURL(tt.url), Proto: "HTTP/1.1", Header: Header{}}
+			_, pattern := mux.Handler(req)
+			if pattern != tt.patternWant {
+				t.Fatalf("pattern got: %q, want: %q", pattern, tt.patternWant)
+			}
+			w := newRecorder()
+			mux.ServeGoHTTP(w, req)
+			if got := w.header.Get("Handler"); got != tt.handlerWant {
+				t.Fatalf("handler got: %q, want: %q", got, tt.handlerWant)
+			}
+			if got := w.header.Get("Location"); got != tt.locationWant {
+				t.Fatalf("location got: %q, want: %q", got, tt.locationWant)
+			}
+			if tt.locationWant != "" && w.status != statusMovedPermanently {
+				t.Fatalf("status code got: %v, want: %v", w.status, statusMovedPermanently)
+			}
+		})
+	}
+}
+
+func TestServeMuxNotFound(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/status", func(w ResponseWriter, req *Request) {})
+
+	req := &Request{Method: "GET", URL: mustParseURL("/other"), Proto: "HTTP/1.1", Header: Header{}}
+	w := newRecorder()
+	mux.ServeGoHTTP(w, req)
+	if w.status != statusNotFound {
+		t.Fatalf("status code got: %v, want: %v", w.status, statusNotFound)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	h := Methods(HandlerFunc(func(w ResponseWriter, req *Request) {
+		w.Write([]byte("served"))
+	}), "GET", "POST")
+
+	var tests = []struct {
+		name       string
+		method     string
+		statusWant int
+		allowWant  string
+		bodyWant   string
+	}{
+		{"Get", "GET", 200, "", "served"},
+		{"Head", "HEAD", 200, "", "served"},
+		{"Post", "POST", 200, "", "served"},
+		{"Options", "OPTIONS", 200, "GET, HEAD, POST, OPTIONS", ""},
+		{"Delete", "DELETE", 405, "GET, HEAD, POST, OPTIONS", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: tt.method, URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{}}
+			w := newRecorder()
+			h.ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			if got := w.header.Get("Allow"); got != tt.allowWant {
+				t.Fatalf("allow got: %q, want: %q", got, tt.allowWant)
+			}
+			if got := w.body.String(); got != tt.bodyWant {
+				t.Fatalf("body got: %q, want: %q", got, tt.bodyWant)
+			}
+		})
+	}
+}
+
+// mustParseURL parses the request target of a request made up by a test.
+func mustParseURL(target string) *url.URL {
+	u, err := parseRequestURI("GET", target)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// recorder is a ResponseWriter that keeps the response in memory.
+type recorder struct {
+	header Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(Header)}
+}
+
+func (r *recorder) Header() Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(statusOK)
+	return r.body.Write(p)
+}
@@ -0,0 +1,87 @@
+package gohttp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Header holds the header fields of a request or response. Its keys
+// are in the canonical format of CanonicalHeaderKey, and each maps to
+// the values of the fields with that name, in the order they were
+// received or added.
+type Header map[string][]string
+
+// Add adds value to the values of key.
+func (h Header) Add(key, value string) {
+	key = CanonicalHeaderKey(key)
+	h[key] = append(h[key], value)
+}
+
+// Set replaces the values of key with value.
+func (h Header) Set(key, value string) {
+	h[CanonicalHeaderKey(key)] = []string{value}
+}
+
+// Get returns the first value of key, or "" if there is none.
+// Use Values to get all of them.
+func (h Header) Get(key string) string {
+	if v := h[CanonicalHeaderKey(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Values returns all values of key. The returned slice is not a copy.
+func (h Header) Values(key string) []string {
+	return h[CanonicalHeaderKey(key)]
+}
+
+// Del deletes the values of key.
+func (h Header) Del(key string) {
+	delete(h, CanonicalHeaderKey(key))
+}
+
+// has reports whether h has a field named key, even with an empty value.
+func (h Header) has(key string) bool {
+	_, ok := h[CanonicalHeaderKey(key)]
+	return ok
+}
+
+// list returns the values of key joined into one comma-separated list.
+// Repeated fields of a list-based header, such as "Accept", are
+// equivalent to a single field with their values joined this way
+// (RFC 9110, Section 5.3).
+func (h Header) list(key string) string {
+	return strings.Join(h.Values(key), ", ")
+}
+
+// Clone returns a copy of h.
+func (h Header) Clone() Header {
+	h2 := make(Header, len(h))
+	for k, v := range h {
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
+
+// parseHeaderLine splits a header field line into its canonical name
+// and its value, without the optional whitespace around it. The name
+// must be a token, directly followed by the colon (RFC 9112, Section 5).
+func parseHeaderLine(line string) (key, value string, err error) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok || key == "" || strings.IndexFunc(key, func(r rune) bool { return !isTokenChar(r) }) != -1 {
+		return "", "", fmt.Errorf("invalid header line found: %v", line)
+	}
+	return CanonicalHeaderKey(key), strings.Trim(value, " \t"), nil
+}
+
+// hasToken reports whether the comma-separated list v,
+// such as a "Connection" header value, contains token.
+func hasToken(v, token string) bool {
+	for _, t := range strings.Split(v, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package gohttp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	h := Header{}
+	h.Add("accept", "text/html")
+	h.Add("ACCEPT", "application/json")
+	h.Set("content-type", "text/plain")
+
+	if got := h.Get("Accept"); got != "text/html" {
+		t.Fatalf("Get got: %q, want: %q", got, "text/html")
+	}
+	if got, want := h.Values("accept"), []string{"text/html", "application/json"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values got: %q, want: %q", got, want)
+	}
+	if got := h.list("Accept"); got != "text/html, application/json" {
+		t.Fatalf("list got: %q, want: %q", got, "text/html, application/json")
+	}
+
+	h.Set("Accept", "*/*")
+	if got, want := h.Values("Accept"), []string{"*/*"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values after Set got: %q, want: %q", got, want)
+	}
+
+	clone := h.Clone()
+	clone.Add("Accept", "text/plain")
+	if len(h.Values("Accept")) != 1 {
+		t.Fatal("changing a clone changed the original header")
+	}
+
+	h.Del("content-TYPE")
+	if h.has("Content-Type") || h.Get("Content-Type") != "" {
+		t.Fatal("Del did not delete the header")
+	}
+}
+
+func TestParseHeaderLine(t *testing.T) {
+	var tests = []struct {
+		name      string
+		line      string
+		keyWant   string
+		valueWant string
+		ok        bool
+	}{
+		{"Basic", "content-type: text/html", "Content-Type", "text/html", true},
+		{"ColonInValue", "Host: localhost:8080", "Host", "localhost:8080", true},
+		{"OptionalWhitespace", "X-Key:\t  a b \t", "X-Key", "a b", true},
+		{"EmptyValue", "X-Key:", "X-Key", "", true},
+		{"NoColon", "X-Key value", "", "", false},
+		{"EmptyName", ": value", "", "", false},
+		{"SpaceInName", "X Key: value", "", "", false},
+		{"SpaceBeforeColon", "X-Key : value", "", "", false},
+		{"Folded", " continued", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, value, err := parseHeaderLine(tt.line)
+			if (err == nil) != tt.ok {
+				t.Fatalf("error got: %v, want ok: %v", err, tt.ok)
+			}
+			if key != tt.keyWant || value != tt.valueWant {
+				t.Fatalf("got: %q %q, want: %q %q", key, value, tt.keyWant, tt.valueWant)
+			}
+		})
+	}
+}
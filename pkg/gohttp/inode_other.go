@@ -0,0 +1,13 @@
+//go:build !unix
+
+package gohttp
+
+import (
+	"os"
+)
+
+// fileInode returns 0, as inode numbers are not available
+// on this platform.
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
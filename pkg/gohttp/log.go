@@ -0,0 +1,33 @@
+package gohttp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardLogger is the logger used when none is configured.
+// It drops every record, so the server is quiet by default.
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return discardLogger
+}
+
+// logger returns the logger of the server handling req,
+// for handlers to report errors with.
+func (req *Request) logger() *slog.Logger {
+	if req.log != nil {
+		return req.log
+	}
+	return discardLogger
+}
@@ -0,0 +1,229 @@
+package gohttp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the buckets
+// of the request duration histogram.
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// pipelineBuckets are the upper bounds of the buckets of the
+// pipeline depth histogram.
+var pipelineBuckets = []float64{1, 2, 4, 8, 16, 32}
+
+// Metrics collects statistics about the requests and connections of
+// the servers recording into it. It is a Handler serving them in the
+// Prometheus text exposition format.
+type Metrics struct {
+	mu               sync.Mutex
+	requests         map[requestLabels]uint64
+	badRequests      map[int]uint64 // by status code
+	responseBytes    uint64
+	duration         *histogram
+	pipelineDepth    *histogram
+	connections      [2]int64 // open connections, by connState
+	connectionsTotal uint64
+	timeouts         map[string]uint64 // by "partial" or "empty" request
+}
+
+type requestLabels struct {
+	method string
+	code   int
+}
+
+// NewMetrics returns a Metrics with all statistics at zero.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:      make(map[requestLabels]uint64),
+		badRequests:   make(map[int]uint64),
+		duration:      newHistogram(durationBuckets),
+		pipelineDepth: newHistogram(pipelineBuckets),
+		timeouts:      make(map[string]uint64),
+	}
+}
+
+// observeRequest records a request served with the given status code,
+// body bytes sent and duration.
+func (m *Metrics) observeRequest(method string, code int, bytes int64, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestLabels{method, code}]++
+	m.responseBytes += uint64(bytes)
+	m.duration.observe(d.Seconds())
+}
+
+// observeBadRequest records a request that could not be read,
+// answered with the given status code.
+func (m *Metrics) observeBadRequest(code int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.badRequests[code]++
+}
+
+// observePipelineDepth records that n requests arrived on a
+// connection before the server had to wait for more.
+func (m *Metrics) observePipelineDepth(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pipelineDepth.observe(float64(n))
+}
+
+// observeTimeout records a connection timing out while waiting for
+// a request, either after part of it ("partial") or before ("empty").
+func (m *Metrics) observeTimeout(request string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts[request]++
+}
+
+// connOpened records a new connection, which starts out idle.
+func (m *Metrics) connOpened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connectionsTotal++
+	m.connections[stateIdle]++
+}
+
+// connStateChanged records a connection going from one state to another.
+func (m *Metrics) connStateChanged(from, to connState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections[from]--
+	m.connections[to]++
+}
+
+// connClosed records the end of a connection in the given state.
+func (m *Metrics) connClosed(state connState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections[state]--
+}
+
+// ServeGoHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeGoHTTP(w ResponseWriter, req *Request) {
+	if !checkMethod(w, req, fileMethods) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ew := &errWriter{w: w}
+
+	ew.printf("# HELP gohttp_requests_total Requests served, by method and status code.\n")
+	ew.printf("# TYPE gohttp_requests_total counter\n")
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].method != labels[j].method {
+			return labels[i].method < labels[j].method
+		}
+		return labels[i].code < labels[j].code
+	})
+	for _, l := range labels {
+		ew.printf("gohttp_requests_total{method=%q,code=\"%d\"} %d\n", l.method, l.code, m.requests[l])
+	}
+
+	ew.printf("# HELP gohttp_bad_requests_total Requests that could not be read, by the status code answered.\n")
+	ew.printf("# TYPE gohttp_bad_requests_total counter\n")
+	for _, code := range sortedKeys(m.badRequests) {
+		ew.printf("gohttp_bad_requests_total{code=\"%d\"} %d\n", code, m.badRequests[code])
+	}
+
+	ew.printf("# HELP gohttp_response_bytes_total Response body bytes sent.\n")
+	ew.printf("# TYPE gohttp_response_bytes_total counter\n")
+	ew.printf("gohttp_response_bytes_total %d\n", m.responseBytes)
+
+	ew.printf("# HELP gohttp_request_duration_seconds Time taken to serve requests.\n")
+	ew.printf("# TYPE gohttp_request_duration_seconds histogram\n")
+	m.duration.write(ew, "gohttp_request_duration_seconds")
+
+	ew.printf("# HELP gohttp_connections Open connections, by state.\n")
+	ew.printf("# TYPE gohttp_connections gauge\n")
+	ew.printf("gohttp_connections{state=\"active\"} %d\n", m.connections[stateActive])
+	ew.printf("gohttp_connections{state=\"idle\"} %d\n", m.connections[stateIdle])
+
+	ew.printf("# HELP gohttp_connections_total Connections accepted.\n")
+	ew.printf("# TYPE gohttp_connections_total counter\n")
+	ew.printf("gohttp_connections_total %d\n", m.connectionsTotal)
+
+	ew.printf("# HELP gohttp_pipeline_depth Requests received in a row on a connection before waiting for more.\n")
+	ew.printf("# TYPE gohttp_pipeline_depth histogram\n")
+	m.pipelineDepth.write(ew, "gohttp_pipeline_depth")
+
+	ew.printf("# HELP gohttp_timeouts_total Connections timed out waiting for a request, by whether part of it was received.\n")
+	ew.printf("# TYPE gohttp_timeouts_total counter\n")
+	for _, request := range []string{"empty", "partial"} {
+		ew.printf("gohttp_timeouts_total{request=%q} %d\n", request, m.timeouts[request])
+	}
+	return ew.n, ew.err
+}
+
+func sortedKeys(m map[int]uint64) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// histogram counts observations in buckets with the given upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.sum += v
+	h.count++
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+}
+
+// write writes h with cumulative bucket counts, as Prometheus expects.
+func (h *histogram) write(ew *errWriter, name string) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		ew.printf("%s_bucket{le=%q} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	ew.printf("%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	ew.printf("%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	ew.printf("%s_count %d\n", name, h.count)
+}
+
+// errWriter writes to w until the first error, which it keeps.
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(ew.w, format, args...)
+	ew.n += int64(n)
+	ew.err = err
+}
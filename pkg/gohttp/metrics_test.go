@@ -0,0 +1,78 @@
+package gohttp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 2, 4})
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		h.observe(v)
+	}
+	var buf bytes.Buffer
+	ew := &errWriter{w: &buf}
+	h.write(ew, "test")
+	want := `test_bucket{le="1"} 2
+test_bucket{le="2"} 2
+test_bucket{le="4"} 3
+test_bucket{le="+Inf"} 4
+test_sum 14.5
+test_count 4
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("histogram got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	m := NewMetrics()
+	s := &Server{
+		Addr:              ":0",
+		DocRoot:           "testdata",
+		Metrics:           m,
+		MetricsPath:       "/metrics",
+		IdleTimeout:       50 * time.Millisecond,
+		ReadHeaderTimeout: 50 * time.Millisecond,
+	}
+
+	// Three pipelined requests, then a timeout
+	roundTrip(t, s, "GET /index.html HTTP/1.1\r\nHost: test\r\n\r\n"+
+		"HEAD /index.html HTTP/1.1\r\nHost: test\r\n\r\n"+
+		"GET /missing.html HTTP/1.1\r\nHost: test\r\n\r\n")
+	// A request that can't be read
+	roundTrip(t, s, "BREW /index.html HTTP/1.1\r\nHost: test\r\n\r\n")
+	// A request cut short by a timeout
+	roundTrip(t, s, "GET /index.html HTTP/1.1\r\nHost")
+
+	res := roundTrip(t, s, "GET /metrics HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+	if !strings.HasPrefix(res, "HTTP/1.1 200 OK\r\n") {
+		t.Fatalf("response got: %q, want status 200", res)
+	}
+	if !strings.Contains(res, "Content-Type: text/plain; version=0.0.4; charset=utf-8\r\n") {
+		t.Fatalf("response got: %q, want the Prometheus content type", res)
+	}
+
+	for _, want := range []string{
+		`gohttp_requests_total{method="GET",code="200"} 1` + "\n",
+		`gohttp_requests_total{method="GET",code="404"} 1` + "\n",
+		`gohttp_requests_total{method="HEAD",code="200"} 1` + "\n",
+		`gohttp_bad_requests_total{code="400"} 1` + "\n",
+		`gohttp_bad_requests_total{code="501"} 1` + "\n",
+		"gohttp_response_bytes_total 12\n",
+		"gohttp_request_duration_seconds_count 3\n",
+		`gohttp_connections{state="active"} 1` + "\n",
+		`gohttp_connections{state="idle"} 0` + "\n",
+		"gohttp_connections_total 4\n",
+		`gohttp_pipeline_depth_bucket{le="2"} 2` + "\n",
+		`gohttp_pipeline_depth_bucket{le="4"} 3` + "\n",
+		`gohttp_timeouts_total{request="empty"} 1` + "\n",
+		`gohttp_timeouts_total{request="partial"} 1` + "\n",
+	} {
+		if !strings.Contains(res, want) {
+			t.Errorf("metrics missing %q in:\n%v", want, res)
+		}
+	}
+}
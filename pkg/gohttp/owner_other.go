@@ -0,0 +1,13 @@
+//go:build !unix
+
+package gohttp
+
+import (
+	"os"
+)
+
+// fileOwner reports the owner as unknown, as file owners
+// are not available on this platform.
+func fileOwner(fi os.FileInfo) (uint32, bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package gohttp
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the user ID of the owner of the file described
+// by fi, and whether it is known.
+func fileOwner(fi os.FileInfo) (uint32, bool) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Uid, true
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package gohttp
+
+import (
+	"os"
+)
+
+// precompressedCodings are the content codings of precompressed
+// sidecar files, with their file extensions, in order of preference.
+var precompressedCodings = []struct {
+	coding string
+	ext    string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// findPrecompressed looks for precompressed siblings of the file at
+// path, under the directory of r, such as path+".gz", that the client accepts according to the
+// "Accept-Encoding" header value acceptEncoding. It returns the path
+// of the file to serve and its content coding, which is "" when the
+// file at path should be served as is. vary reports whether any sibling
+// exists, in which case the response depends on "Accept-Encoding".
+func findPrecompressed(r *os.Root, path, acceptEncoding string) (servePath, coding string, vary bool) {
+	var available []string
+	for _, pc := range precompressedCodings {
+		fi, err := statIn(r, path+pc.ext)
+		if err == nil && fi.Mode().IsRegular() {
+			available = append(available, pc.coding)
+		}
+	}
+	if len(available) == 0 {
+		return path, "", false
+	}
+	coding = negotiateEncoding(acceptEncoding, available...)
+	for _, pc := range precompressedCodings {
+		if pc.coding == coding {
+			return path + pc.ext, coding, true
+		}
+	}
+	return path, "", true
+}
@@ -0,0 +1,137 @@
+package gohttp
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestPrecompressed(t *testing.T) {
+	var tests = []struct {
+		name             string
+		header           Header
+		statusWant       int
+		filePathWant     string // relative to doc root
+		headerValuesWant map[string]string
+	}{
+		{
+			"Identity",
+			Header{},
+			200,
+			"precompressed.html",
+			map[string]string{
+				"Content-Type": contentTypeHTML,
+				"Vary":         "Accept-Encoding",
+			},
+		},
+		{
+			"Gzip",
+			Header{"Accept-Encoding": {"gzip, deflate"}},
+			200,
+			"precompressed.html.gz",
+			map[string]string{
+				"Content-Type":     contentTypeHTML,
+				"Content-Encoding": "gzip",
+				"Vary":             "Accept-Encoding",
+			},
+		},
+		{
+			"Brotli",
+			Header{"Accept-Encoding": {"gzip, br"}},
+			200,
+			"precompressed.html.br",
+			map[string]string{
+				"Content-Type":     contentTypeHTML,
+				"Content-Encoding": "br",
+			},
+		},
+		{
+			"QValues",
+			Header{"Accept-Encoding": {"gzip, br;q=0.1"}},
+			200,
+			"precompressed.html.gz",
+			map[string]string{
+				"Content-Encoding": "gzip",
+			},
+		},
+		{
+			"NoSidecar",
+			Header{"Accept-Encoding": {"zstd"}},
+			200,
+			"precompressed.html",
+			map[string]string{
+				"Vary": "Accept-Encoding",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Addr: ":0", DocRoot: "testdata"}
+			req := &Request{Method: "GET", URL: mustParseURL("/precompressed.html"), Proto: "HTTP/1.1", Header: tt.header, Body: NoBody}
+			res := s.HandleGoodRequest(req)
+			if res.StatusCode != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", res.StatusCode, tt.statusWant)
+			}
+			filePath, err := normalizeTestdataPath(res.FilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filePath != tt.filePathWant {
+				t.Fatalf("file path got: %q, want: %q", filePath, tt.filePathWant)
+			}
+			for h, vWant := range tt.headerValuesWant {
+				if v := res.Header.Get(h); v != vWant {
+					t.Fatalf("header %q value got: %q, want %q", h, v, vWant)
+				}
+			}
+			if _, ok := tt.headerValuesWant["Content-Encoding"]; !ok {
+				if v := res.Header.Values("Content-Encoding"); v != nil {
+					t.Fatalf("unexpected content encoding %q", v)
+				}
+			}
+		})
+	}
+}
+
+func TestPrecompressedRangeAndConditional(t *testing.T) {
+	s := &Server{Addr: ":0", DocRoot: "testdata"}
+	fi, err := os.Stat("testdata/precompressed.html.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ranges apply to the compressed bytes
+	req := &Request{
+		Method: "GET",
+		URL:    mustParseURL("/precompressed.html"),
+		Proto:  "HTTP/1.1",
+		Header: Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-1"}},
+		Body:   NoBody,
+	}
+	res := s.HandleGoodRequest(req)
+	if res.StatusCode != 206 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 206)
+	}
+	if got, want := res.Header.Get("Content-Range"), fmt.Sprintf("bytes 0-1/%d", fi.Size()); got != want {
+		t.Fatalf("content range got: %q, want: %q", got, want)
+	}
+	w := newRecorder()
+	if err := res.Send(w); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.body.String(); got != "\x1f\x8b" {
+		t.Fatalf("body got: %q, want the gzip magic number", got)
+	}
+
+	// Validators are the ones of the compressed file
+	etag := fileETag(fi, false)
+	req.Header = Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}}
+	if res := s.HandleGoodRequest(req); res.StatusCode != 304 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 304)
+	}
+	req.Header = Header{"If-None-Match": {etag}}
+	if res := s.HandleGoodRequest(req); res.StatusCode != 200 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 200)
+	}
+}
@@ -0,0 +1,225 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errInvalidRange means the "Range" header can't be understood,
+	// in which case it is ignored and the whole file is served.
+	errInvalidRange = errors.New("invalid range")
+
+	// errNoOverlap means none of the ranges asked for are within the
+	// file, which gets a 416 Range Not Satisfiable response.
+	errNoOverlap = errors.New("no requested range overlaps the file")
+)
+
+// httpRange is a byte range of a file.
+type httpRange struct {
+	start, length int64
+}
+
+// contentRange returns the "Content-Range" header value for r
+// in a file of the given size.
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange parses a "Range" header value, such as "bytes=0-99,200-",
+// for a file of the given size. Ranges entirely outside the file are
+// dropped, and ranges running past its end are truncated.
+func parseRange(s string, size int64) ([]httpRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errInvalidRange
+	}
+	var ranges []httpRange
+	noOverlap := false
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.IndexByte(spec, '-')
+		if i < 0 {
+			return nil, errInvalidRange
+		}
+		first, last := spec[:i], spec[i+1:]
+
+		var r httpRange
+		if first == "" {
+			// "-n" asks for the last n bytes
+			n, err := parseRangeInt(last)
+			if err != nil {
+				return nil, err
+			}
+			// An empty representation has no last bytes to send
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = httpRange{start: size - n, length: n}
+		} else {
+			start, err := parseRangeInt(first)
+			if err != nil {
+				return nil, err
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = parseRangeInt(last); err != nil {
+					return nil, err
+				}
+				if end < start {
+					return nil, errInvalidRange
+				}
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			if end >= size {
+				end = size - 1
+			}
+			r = httpRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errNoOverlap
+		}
+		return nil, errInvalidRange
+	}
+	return ranges, nil
+}
+
+func parseRangeInt(s string) (int64, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, errInvalidRange
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errInvalidRange
+	}
+	return n, nil
+}
+
+// checkIfRange reports whether the "If-Range" header of req, if any,
+// still matches res, so that its "Range" header applies.
+func checkIfRange(req *Request, res *Response) bool {
+	if !req.Header.has("If-Range") {
+		return true
+	}
+	ir := req.Header.Get("If-Range")
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		// Only a strong validator can match (RFC 9110, Section 13.1.5)
+		etag := res.Header.Get("Etag")
+		return etag != "" && !strings.HasPrefix(ir, "W/") && ir == etag
+	}
+	t, err := ParseTime(ir)
+	if err != nil {
+		return false
+	}
+	return FormatTime(t) == res.Header.Get("Last-Modified")
+}
+
+// handleRange turns res, a 200 OK response for the file at res.FilePath,
+// into a 206 Partial Content or 416 Range Not Satisfiable response
+// as asked for by the "Range" header of req. It leaves res alone
+// if req doesn't ask for a usable range.
+func (res *Response) handleRange(req *Request) {
+	res.Header.Set("Accept-Ranges", "bytes")
+	rangeHeader := req.Header.Get("Range")
+	if !req.Header.has("Range") || res.StatusCode != statusOK || (req.Method != "GET" && req.Method != "HEAD") {
+		return
+	}
+	if !checkIfRange(req, res) {
+		return
+	}
+	fi, err := res.statFile()
+	if err != nil {
+		return
+	}
+	size := fi.Size()
+
+	ranges, err := parseRange(rangeHeader, size)
+	if errors.Is(err, errNoOverlap) {
+		res.StatusCode = statusRangeNotSatisfiable
+		res.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		res.Header.Set("Content-Length", "0")
+		res.Header.Del("Content-Type")
+		res.FilePath = ""
+		return
+	}
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, r := range ranges {
+		total += r.length
+	}
+	if total > size {
+		// Overlapping ranges cost more than the whole file
+		return
+	}
+
+	f, err := res.openFile()
+	if err != nil {
+		return
+	}
+	res.StatusCode = statusPartialContent
+	res.FilePath = ""
+	if len(ranges) == 1 {
+		r := ranges[0]
+		res.Header.Set("Content-Range", r.contentRange(size))
+		res.Header.Set("Content-Length", strconv.FormatInt(r.length, 10))
+		res.Body = &fileSection{io.NewSectionReader(f, r.start, r.length), f}
+		return
+	}
+
+	// Several ranges are sent as the parts of a multipart/byteranges body.
+	// The part headers are known upfront, so the length of the body is too.
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	contentType := res.Header.Get("Content-Type")
+	var parts []io.Reader
+	var length int64
+	for i, r := range ranges {
+		partHeader := fmt.Sprintf("--%s\r\n", boundary)
+		if i > 0 {
+			partHeader = "\r\n" + partHeader
+		}
+		if contentType != "" {
+			partHeader += fmt.Sprintf("Content-Type: %s\r\n", contentType)
+		}
+		partHeader += fmt.Sprintf("Content-Range: %s\r\n\r\n", r.contentRange(size))
+		parts = append(parts, strings.NewReader(partHeader), io.NewSectionReader(f, r.start, r.length))
+		length += int64(len(partHeader)) + r.length
+	}
+	end := fmt.Sprintf("\r\n--%s--\r\n", boundary)
+	parts = append(parts, strings.NewReader(end))
+	length += int64(len(end))
+
+	res.Header.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	res.Header.Set("Content-Length", strconv.FormatInt(length, 10))
+	res.Body = &fileSection{io.MultiReader(parts...), f}
+}
+
+// fileSection is a response body read from part of an open file,
+// which is closed along with the body.
+type fileSection struct {
+	io.Reader
+	f *os.File
+}
+
+func (fs *fileSection) Close() error {
+	return fs.f.Close()
+}
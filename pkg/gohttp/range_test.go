@@ -0,0 +1,221 @@
+package gohttp
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	var tests = []struct {
+		name       string
+		header     string
+		size       int64
+		rangesWant []httpRange
+		errWant    error
+	}{
+		{"Single", "bytes=0-4", 12, []httpRange{{0, 5}}, nil},
+		{"OpenEnded", "bytes=6-", 12, []httpRange{{6, 6}}, nil},
+		{"Suffix", "bytes=-5", 12, []httpRange{{7, 5}}, nil},
+		{"SuffixLongerThanFile", "bytes=-100", 12, []httpRange{{0, 12}}, nil},
+		{"PastEnd", "bytes=6-100", 12, []httpRange{{6, 6}}, nil},
+		{"Multiple", "bytes=0-1, 4-5,,-2", 12, []httpRange{{0, 2}, {4, 2}, {10, 2}}, nil},
+		{"DropsOutside", "bytes=0-1,20-30", 12, []httpRange{{0, 2}}, nil},
+		{"NoOverlap", "bytes=12-", 12, nil, errNoOverlap},
+		{"ZeroSuffix", "bytes=-0", 12, nil, errNoOverlap},
+		{"SuffixOfEmptyFile", "bytes=-5", 0, nil, errNoOverlap},
+		{"OtherUnit", "items=0-1", 12, nil, errInvalidRange},
+		{"Reversed", "bytes=5-1", 12, nil, errInvalidRange},
+		{"Garbage", "bytes=a-b", 12, nil, errInvalidRange},
+		{"NoDash", "bytes=5", 12, nil, errInvalidRange},
+		{"Empty", "bytes=", 12, nil, errInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges, err := parseRange(tt.header, tt.size)
+			if err != tt.errWant {
+				t.Fatalf("error got: %v, want: %v", err, tt.errWant)
+			}
+			if !reflect.DeepEqual(ranges, tt.rangesWant) {
+				t.Fatalf("ranges got: %v, want: %v", ranges, tt.rangesWant)
+			}
+		})
+	}
+}
+
+func TestHandleRange(t *testing.T) {
+	fi, err := os.Stat("testdata/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastModified := FormatTime(fi.ModTime())
+
+	var tests = []struct {
+		name             string
+		header           Header
+		statusWant       int
+		headerValuesWant map[string]string
+		bodyWant         string
+	}{
+		{
+			"NoRange",
+			Header{},
+			200,
+			map[string]string{
+				"Accept-Ranges":  "bytes",
+				"Content-Length": "12",
+			},
+			"Hello World\n",
+		},
+		{
+			"Single",
+			Header{
+				"Range": {"bytes=6-10"},
+			},
+			206,
+			map[string]string{
+				"Accept-Ranges":  "bytes",
+				"Content-Length": "5",
+				"Content-Range":  "bytes 6-10/12",
+				"Content-Type":   contentTypeHTML,
+			},
+			"World",
+		},
+		{
+			"NotSatisfiable",
+			Header{
+				"Range": {"bytes=100-"},
+			},
+			416,
+			map[string]string{
+				"Content-Length": "0",
+				"Content-Range":  "bytes */12",
+			},
+			"",
+		},
+		{
+			"IfRangeMatches",
+			Header{
+				"Range":    {"bytes=0-4"},
+				"If-Range": {lastModified},
+			},
+			206,
+			map[string]string{
+				"Content-Range": "bytes 0-4/12",
+			},
+			"Hello",
+		},
+		{
+			"IfRangeStale",
+			Header{
+				"Range":    {"bytes=0-4"},
+				"If-Range": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			},
+			200,
+			map[string]string{
+				"Content-Length": "12",
+			},
+			"Hello World\n",
+		},
+		{
+			"IfRangeWeakETag",
+			Header{
+				"Range":    {"bytes=0-4"},
+				"If-Range": {`W/"abc"`},
+			},
+			200,
+			map[string]string{
+				"Content-Length": "12",
+			},
+			"Hello World\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Addr: ":0", DocRoot: "testdata"}
+			req := &Request{Method: "GET", URL: mustParseURL("/index.html"), Proto: "HTTP/1.1", Header: tt.header, Body: NoBody}
+			res := s.HandleGoodRequest(req)
+			if res.StatusCode != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", res.StatusCode, tt.statusWant)
+			}
+			for h, vWant := range tt.headerValuesWant {
+				if v := res.Header.Get(h); v != vWant {
+					t.Fatalf("header %q value got: %q, want %q", h, v, vWant)
+				}
+			}
+			w := newRecorder()
+			if err := res.Send(w); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.body.String(); got != tt.bodyWant {
+				t.Fatalf("body got: %q, want: %q", got, tt.bodyWant)
+			}
+		})
+	}
+}
+
+func TestHandleMultipleRanges(t *testing.T) {
+	s := &Server{Addr: ":0", DocRoot: "testdata"}
+	req := &Request{
+		Method: "GET",
+		URL:    mustParseURL("/index.html"),
+		Proto:  "HTTP/1.1",
+		Header: Header{"Range": {"bytes=0-4,-6"}},
+		Body:   NoBody,
+	}
+	res := s.HandleGoodRequest(req)
+	if res.StatusCode != 206 {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, 206)
+	}
+	w := newRecorder()
+	if err := res.Send(w); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.header.Get("Content-Length"), len(w.body.String()); got != strconv.Itoa(want) {
+		t.Fatalf("content length got: %v, want: %v", got, want)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(w.header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/byteranges" {
+		t.Fatalf("content type got: %q, want multipart/byteranges", mediaType)
+	}
+	mr := multipart.NewReader(&w.body, params["boundary"])
+	for _, want := range []struct{ contentRange, body string }{
+		{"bytes 0-4/12", "Hello"},
+		{"bytes 6-11/12", "World\n"},
+	} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := part.Header.Get("Content-Range"); got != want.contentRange {
+			t.Fatalf("part content range got: %q, want: %q", got, want.contentRange)
+		}
+		if got := part.Header.Get("Content-Type"); got != contentTypeHTML {
+			t.Fatalf("part content type got: %q, want: %q", got, contentTypeHTML)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want.body {
+			t.Fatalf("part body got: %q, want: %q", body, want.body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Fatalf("got error: %v, want: %v", err, io.EOF)
+	}
+	if strings.Contains(w.header.Get("Content-Type"), contentTypeHTML) {
+		t.Fatalf("content type got: %q, want multipart only", w.header.Get("Content-Type"))
+	}
+}
@@ -0,0 +1,126 @@
+package gohttp
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HandleRedirect prepares res to be a redirect response with statusCode,
+// such as 301 Moved Permanently, sending the client to location.
+func (res *Response) HandleRedirect(req *Request, location string, statusCode int) {
+	res.Header.Set("Date", FormatTime(time.Now()))
+	res.Header.Set("Location", location)
+	res.Header.Set("Content-Length", "0")
+	res.Proto = responseProto
+	res.StatusCode = statusCode
+	res.FilePath = ""
+	if req.Close {
+		res.Header.Set("Connection", "close")
+	}
+}
+
+// Redirect replies to req with a redirect to location, which may be a
+// path relative to the request, and statusCode, such as 301 Moved
+// Permanently or 307 Temporary Redirect.
+func Redirect(w ResponseWriter, req *Request, location string, statusCode int) {
+	res := &Response{
+		Header:  make(Header),
+		Request: req,
+	}
+	res.HandleRedirect(req, location, statusCode)
+	res.Send(w)
+}
+
+// A RedirectRule redirects requests for a path to another URL.
+type RedirectRule struct {
+	// From is the path to redirect. If it ends in "/", the rule is a
+	// prefix rule redirecting the whole subtree, e.g. "/old/" matches
+	// "/old/a.html", which is redirected to To followed by "a.html".
+	From string
+
+	// To is the URL or path to redirect to.
+	To string
+
+	// Code is the status code of the redirect:
+	// 301, 302, 307 or 308.
+	Code int
+}
+
+// Redirects returns a handler that redirects requests matching one of
+// rules, and passes the others on to h. The query string of a request
+// is kept in the redirect. Exact rules take precedence over prefix
+// rules, and longer prefixes take precedence over shorter ones.
+// It panics if a rule is invalid.
+func Redirects(h Handler, rules []RedirectRule) Handler {
+	rh := &redirectHandler{
+		handler: h,
+		exact:   make(map[string]RedirectRule),
+	}
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			panic("gohttp: " + err.Error())
+		}
+		if strings.HasSuffix(r.From, "/") {
+			rh.prefixes = append(rh.prefixes, r)
+		} else {
+			rh.exact[r.From] = r
+		}
+	}
+	sort.SliceStable(rh.prefixes, func(i, j int) bool {
+		return len(rh.prefixes[i].From) > len(rh.prefixes[j].From)
+	})
+	return rh
+}
+
+// validate returns an error if r can't be used as a redirect rule.
+func (r RedirectRule) validate() error {
+	if r.From == "" || r.From[0] != '/' {
+		return fmt.Errorf("invalid redirect from %q", r.From)
+	}
+	if r.Code != statusMovedPermanently && r.Code != statusFound &&
+		r.Code != statusTemporaryRedirect && r.Code != statusPermanentRedirect {
+		return fmt.Errorf("invalid redirect status code %v for %v", r.Code, r.From)
+	}
+	return nil
+}
+
+type redirectHandler struct {
+	handler  Handler
+	exact    map[string]RedirectRule
+	prefixes []RedirectRule // sorted from longest to shortest From
+}
+
+func (rh *redirectHandler) ServeGoHTTP(w ResponseWriter, req *Request) {
+	// Match the clean path, as the handlers behind would serve it
+	urlPath, rawQuery := canonicalPath(req.URL.Path), req.URL.RawQuery
+	if r, ok := rh.exact[urlPath]; ok {
+		Redirect(w, req, withQuery(r.To, rawQuery), r.Code)
+		return
+	}
+	for _, r := range rh.prefixes {
+		if strings.HasPrefix(urlPath, r.From) {
+			rest := (&url.URL{Path: urlPath[len(r.From):]}).EscapedPath()
+			Redirect(w, req, withQuery(r.To+rest, rawQuery), r.Code)
+			return
+		}
+	}
+	rh.handler.ServeGoHTTP(w, req)
+}
+
+func (rh *redirectHandler) methods() []string {
+	return handlerMethods(rh.handler)
+}
+
+// withQuery adds rawQuery, if not empty, to the query string of location.
+func withQuery(location, rawQuery string) string {
+	if rawQuery == "" {
+		return location
+	}
+	if strings.Contains(location, "?") {
+		return location + "&" + rawQuery
+	}
+	return location + "?" + rawQuery
+}
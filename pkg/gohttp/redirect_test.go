@@ -0,0 +1,103 @@
+package gohttp
+
+import "testing"
+
+func TestRedirects(t *testing.T) {
+	next := HandlerFunc(func(w ResponseWriter, req *Request) {
+		w.Header().Set("Handler", "next")
+	})
+	h := Redirects(next, []RedirectRule{
+		{From: "/about.html", To: "/about/", Code: 301},
+		{From: "/old/", To: "/new/", Code: 308},
+		{From: "/old/docs/", To: "https://docs.example.com/", Code: 302},
+		{From: "/search", To: "/find?v=2", Code: 307},
+	})
+
+	var tests = []struct {
+		name         string
+		url          string
+		statusWant   int
+		locationWant string
+	}{
+		{"Exact", "/about.html", 301, "/about/"},
+		{"ExactWithQuery", "/about.html?lang=en", 301, "/about/?lang=en"},
+		{"ExactNotPrefix", "/about.html/more", 200, ""},
+		{"Prefix", "/old/a/b.html", 308, "/new/a/b.html"},
+		{"PrefixRoot", "/old/", 308, "/new/"},
+		{"PrefixEscaped", "/old/a%20b.html", 308, "/new/a%20b.html"},
+		{"LongestPrefix", "/old/docs/intro.html", 302, "https://docs.example.com/intro.html"},
+		{"MergedQuery", "/search?q=go", 307, "/find?v=2&q=go"},
+		{"UncleanPath", "/x/../old/a.html", 308, "/new/a.html"},
+		{"DoubleSlash", "//about.html", 301, "/about/"},
+		{"NoMatch", "/index.html", 200, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: "GET", URL: mustParseURL(tt.url), Proto: "HTTP/1.1", Header: Header{}}
+			w := newRecorder()
+			h.ServeGoHTTP(w, req)
+			status := w.status
+			if status == 0 {
+				status = statusOK
+			}
+			if status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", status, tt.statusWant)
+			}
+			if got := w.header.Get("Location"); got != tt.locationWant {
+				t.Fatalf("location got: %q, want: %q", got, tt.locationWant)
+			}
+			if tt.statusWant == statusOK && w.header.Get("Handler") != "next" {
+				t.Fatal("request not passed on to the next handler")
+			}
+		})
+	}
+}
+
+func TestRedirectsInvalidCode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Redirects did not panic for status code 200")
+		}
+	}()
+	Redirects(NotFoundHandler(), []RedirectRule{{From: "/a", To: "/b", Code: 200}})
+}
+
+func TestValidateServerSetupRedirects(t *testing.T) {
+	for _, r := range []RedirectRule{{Code: 303}, {From: "old", To: "/new", Code: 301}} {
+		s := &Server{Addr: ":0", Handler: NotFoundHandler(), Redirects: []RedirectRule{r}}
+		if err := s.ValidateServerSetup(); err == nil {
+			t.Fatalf("ValidateServerSetup got no error for rule %+v", r)
+		}
+	}
+}
+
+func TestFileHandlerDirectoryRedirect(t *testing.T) {
+	var tests = []struct {
+		name         string
+		url          string
+		statusWant   int
+		locationWant string
+	}{
+		{"MissingSlash", "/subdir", 301, "/subdir/"},
+		{"MissingSlashWithQuery", "/subdir?a=1&b=2", 301, "/subdir/?a=1&b=2"},
+		{"DoubleSlash", "//subdir", 301, "/subdir/"},
+		{"DotDot", "/subdir/../subdir", 301, "/subdir/"},
+		{"Slash", "/subdir/", 200, ""},
+		{"File", "/index.html", 200, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: "GET", URL: mustParseURL(tt.url), Proto: "HTTP/1.1", Header: Header{}}
+			w := newRecorder()
+			FileServer("testdata").ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			if got := w.header.Get("Location"); got != tt.locationWant {
+				t.Fatalf("location got: %q, want: %q", got, tt.locationWant)
+			}
+		})
+	}
+}
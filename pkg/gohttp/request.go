@@ -2,23 +2,131 @@ package gohttp
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/url"
 	"strings"
 )
 
+// knownMethods are the request methods defined by RFC 9110 and RFC 5789.
+// A request with any other method gets 501 Not Implemented.
+var knownMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"DELETE":  true,
+	"CONNECT": true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PATCH":   true,
+}
+
+const (
+	// defaultMaxHeaderBytes is the default limit on the size of the
+	// request line and headers of a request, line ends included.
+	defaultMaxHeaderBytes = 1 << 20
+
+	// defaultMaxURIBytes is the default limit on the length of
+	// the URL in the request line.
+	defaultMaxURIBytes = 8 << 10
+)
+
+// errLineTooLong is returned by readLineLimit for a line
+// longer than allowed.
+var errLineTooLong = errors.New("line too long")
+
+// statusError is an error reading a request that should be answered
+// with a specific status code rather than 400 Bad Request.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// errorStatus returns the status code to respond with when
+// reading a request fails with err.
+func errorStatus(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code
+	}
+	return statusBadRequest
+}
+
 type Request struct {
 	Method string // e.g. "GET"
-	URL    string // e.g. "/path/to/a/file"
-	Proto  string // e.g. "HTTP/1.1"
+
+	// URL is the parsed request target. Its Path is percent-decoded,
+	// e.g. "/path/to/a file", with the encoded form in RawPath when it
+	// differs, and the query string, if any, is in RawQuery.
+	URL *url.URL
+
+	// RequestURI is the request target as sent in the request line,
+	// e.g. "/path/to/a%20file?v=2".
+	RequestURI string
+
+	Proto string // e.g. "HTTP/1.1"
 
 	// Header stores misc headers excluding "Host" and "Connection",
-	// which are stored in special fields below.
-	// Header keys are case-incensitive, and should be stored
-	// in the canonical format in this map.
-	Header map[string]string
+	// which are stored in special fields below. Repeated headers
+	// keep all their values, in the order received.
+	Header Header
+
+	Host string // determine from the "Host" header
+
+	// Close is determined from the "Connection" header. It defaults
+	// to false for HTTP/1.1 requests, which are closed with
+	// "Connection: close", and to true for HTTP/1.0 requests, which
+	// are kept alive with "Connection: keep-alive".
+	Close bool
+
+	// ContentLength is the length of the body in bytes, determined
+	// from the "Content-Length" header. It is -1 for a chunked body,
+	// whose length is unknown until it has been read.
+	ContentLength int64
+
+	// Body is the request body. It is never nil; a request without a
+	// body has NoBody. Whatever the handler leaves unread is discarded
+	// by the server before the next request on the connection is read.
+	//
+	// If the client sent "Expect: 100-continue", it waits for the server
+	// to send "100 Continue" before sending the body, which happens when
+	// the handler first reads Body. A handler answering without reading
+	// Body saves the client the upload, and the connection is closed.
+	Body io.ReadCloser
 
-	Host  string // determine from the "Host" header
-	Close bool   // determine from the "Connection" header
+	// Trailer stores the trailer fields sent after a chunked body,
+	// with keys in the canonical format. It is only filled in
+	// once Body has been read to io.EOF.
+	Trailer Header
+
+	// Form holds the values of the query string and of a form in the
+	// body. It is only filled in once ParseForm or ParseMultipartForm
+	// has been called.
+	Form url.Values
+
+	// PostForm holds the values of a form in the body, without those
+	// of the query string. It is filled in along with Form.
+	PostForm url.Values
+
+	// MultipartForm is the parsed multipart/form-data body, including
+	// its files. It is only filled in once ParseMultipartForm has
+	// been called.
+	MultipartForm *multipart.Form
+
+	// RemoteAddr is the network address of the client that sent the
+	// request, e.g. "192.0.2.1:54321". It is set by the server.
+	RemoteAddr string
+
+	log     *slog.Logger // the logger of the server, see Request.logger
+	session *Session     // the session given by Sessions, see Request.Session
 }
 
 // ReadRequest tries to read the next valid request from br.
@@ -30,84 +138,203 @@ type Request struct {
 // and a nil request. In this case, bytesReceived indicates whether or not
 // some bytes are received before the error occurs. This is useful to determine
 // the timeout with partial request received condition.
+//
+// The request target may be a path with a query string, a URL as sent
+// to proxies (absolute-form), whose host then takes the place of the
+// "Host" header, or "*" for a server-wide OPTIONS request. A target
+// with a malformed percent-encoding is an error.
+//
+// A request with a URL longer than 8KB gets a 414 URI Too Long error,
+// and one with more than 1MB of headers a 431 Request Header Fields
+// Too Large error.
 func ReadRequest(br *bufio.Reader) (req *Request, bytesReceived bool, err error) {
+	return readRequest(br, defaultMaxHeaderBytes, defaultMaxURIBytes)
+}
+
+// readRequest is like ReadRequest, with limits on the size in bytes of
+// the request line and headers, and on the length of the URL.
+func readRequest(br *bufio.Reader, maxHeaderBytes, maxURIBytes int) (req *Request, bytesReceived bool, err error) {
 	req = &Request{
-		Header: make(map[string]string),
+		Header: make(Header),
 	}
 
 	// Read start line
-	line, err := ReadLine(br)
-
+	line, err := readLineLimit(br, maxHeaderBytes)
+	if errors.Is(err, errLineTooLong) {
+		// Only the URL can make a request line this long
+		return nil, true, &statusError{statusURITooLong, "request line too long"}
+	}
 	if err != nil {
-		return nil, false, err
+		return nil, line != "", err
 	}
+	headerBytes := len(line) + 2
 	// Parse the request status line
-	req.Method, req.URL, req.Proto, req.Host, err = parseRequestLine(line)
+	req.Method, req.RequestURI, req.Proto, req.Host, err = parseRequestLine(line)
 	if err != nil {
 		return nil, true, err
 	}
-	// Check for GET HTTP verb
-	if req.Method != "GET" {
-		return nil, true, fmt.Errorf("invalid method found: %v", req.Method)
+	// protocol should be HTTP/1.x
+	major, _, ok := parseHTTPVersion(req.Proto)
+	if !ok {
+		return nil, true, fmt.Errorf("invalid protocol found: %v", req.Proto)
+	}
+	if major != 1 {
+		return nil, true, &statusError{statusHTTPVersionNotSupported, fmt.Sprintf("protocol not supported: %v", req.Proto)}
 	}
 
-	// url should start with '/'
-	if req.URL[0] != '/' {
-		return nil, true, fmt.Errorf("invalid url found: %v", req.URL)
+	// Check the HTTP verb is a token, and one we know about
+	if req.Method == "" || strings.IndexFunc(req.Method, func(r rune) bool { return !isTokenChar(r) }) != -1 {
+		return nil, true, fmt.Errorf("invalid method found: %q", req.Method)
+	}
+	if !knownMethods[req.Method] {
+		return nil, true, &statusError{statusNotImplemented, fmt.Sprintf("method not implemented: %v", req.Method)}
 	}
 
-	// protocol should be HTTP/1.1
-	if req.Proto != "HTTP/1.1" {
-		return nil, true, fmt.Errorf("invalid protocol found: %v", req.Proto)
+	if len(req.RequestURI) > maxURIBytes {
+		return nil, true, &statusError{statusURITooLong, fmt.Sprintf("url longer than %v bytes", maxURIBytes)}
+	}
+	if req.URL, err = parseRequestURI(req.Method, req.RequestURI); err != nil {
+		return nil, true, err
 	}
 
 	// Read headers
-	req.Header = make(map[string]string)
+	req.Header = make(Header)
+	hasHost := false
+	var connection []string
 	for {
-		line, err := ReadLine(br)
-		// fmt.Print("line: ", line, "\n")
-		if line == "" {
-			break
+		line, err := readLineLimit(br, maxHeaderBytes-headerBytes)
+		if errors.Is(err, errLineTooLong) {
+			return nil, true, &statusError{statusRequestHeaderFieldsTooLarge, fmt.Sprintf("headers larger than %v bytes", maxHeaderBytes)}
 		}
 		if err != nil {
-			fmt.Print(err.Error())
-			return req, true, err
+			return nil, true, err
 		}
-
-		spilted := strings.Split(line, ":")
-		// seperate the header key and value
-		// key should not have spaces, only alphanumeric characters and numbers
-		IsLetter := func(r rune) bool {
-			return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
-		}
-		IsNumber := func(r rune) bool {
-			return (r >= '0' && r <= '9')
+		headerBytes += len(line) + 2
+		if line == "" {
+			break
 		}
-		IsAlphanumeric := func(r rune) bool {
-			return IsLetter(r) || IsNumber(r) || r == '-'
+
+		// Split the line at the first colon only, so that values such as
+		// "localhost:8080" keep theirs
+		key, value, err := parseHeaderLine(line)
+		if err != nil {
+			return nil, true, err
 		}
-		// check if the key is valid
-		key := spilted[0]
-
-		if strings.IndexFunc(key, IsAlphanumeric) != -1 {
-			// check if the value is valid
-			value := strings.TrimLeft(spilted[1], " ")
-			key = CanonicalHeaderKey(key)
-			if key == "Host" {
-				req.Host = value
-			} else if key == "Connection" {
-				req.Close = value == "close"
-			} else {
-				req.Header[key] = value
+		switch key {
+		case "Host":
+			if hasHost {
+				return nil, true, fmt.Errorf("more than one Host header found")
 			}
-		} else {
-			return nil, true, fmt.Errorf("invalid header key found: %v", key)
+			req.Host = value
+			hasHost = true
+		case "Connection":
+			connection = append(connection, value)
+		default:
+			req.Header.Add(key, value)
 		}
+	}
+
+	// The host of an absolute-form target overrides the "Host" header
+	// (RFC 9112, Section 3.2.2)
+	if req.URL.IsAbs() {
+		req.Host = req.URL.Host
+		hasHost = true
+	}
+
+	// HTTP/1.1 requests must say which host they are for
+	if !hasHost && !req.http10() {
+		return nil, true, fmt.Errorf("missing Host header")
+	}
+
+	// The only expectation defined is "100-continue", which HTTP/1.0
+	// clients can't have (RFC 9110, Section 10.1.1)
+	if expect := req.Header.Get("Expect"); expect != "" && !req.http10() && !strings.EqualFold(expect, "100-continue") {
+		return nil, true, &statusError{statusExpectationFailed, fmt.Sprintf("unsupported expectation: %v", expect)}
+	}
+
+	// HTTP/1.0 connections are closed after each request,
+	// unless the client asks to keep them alive
+	if req.http10() {
+		req.Close = !hasToken(strings.Join(connection, ","), "keep-alive")
+	} else {
+		req.Close = hasToken(strings.Join(connection, ","), "close")
+	}
 
+	// Set up the body, if any
+	if err := readBody(req, br, maxHeaderBytes); err != nil {
+		return nil, true, err
 	}
 	return req, true, nil
 }
 
+// isTokenChar reports whether r may appear in an HTTP token,
+// such as a method name.
+func isTokenChar(r rune) bool {
+	if r >= 0x80 || r <= ' ' || r == 0x7f {
+		return false
+	}
+	return !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+}
+
+// parseHTTPVersion parses an HTTP version such as "HTTP/1.1"
+// (RFC 9112, Section 2.3).
+func parseHTTPVersion(proto string) (major, minor int, ok bool) {
+	if len(proto) != len("HTTP/x.y") || !strings.HasPrefix(proto, "HTTP/") || proto[6] != '.' {
+		return 0, 0, false
+	}
+	if !isDigit(proto[5]) || !isDigit(proto[7]) {
+		return 0, 0, false
+	}
+	return int(proto[5] - '0'), int(proto[7] - '0'), true
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// http10 reports whether req is an HTTP/1.0 request. Any other
+// HTTP/1.x version is handled as HTTP/1.1.
+func (req *Request) http10() bool {
+	return req.Proto == "HTTP/1.0"
+}
+
+// expectsContinue reports whether the client of req waits for
+// "100 Continue" before sending the body.
+func (req *Request) expectsContinue() bool {
+	return !req.http10() && strings.EqualFold(req.Header.Get("Expect"), "100-continue")
+}
+
+// parseRequestURI parses the request target of a request with the given
+// method. A fragment, which clients shouldn't send, is dropped.
+func parseRequestURI(method, target string) (*url.URL, error) {
+	if target == "*" {
+		if method != "OPTIONS" {
+			return nil, fmt.Errorf("invalid url found: %v", target)
+		}
+		return &url.URL{Path: "*"}, nil
+	}
+	target, _, _ = strings.Cut(target, "#")
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url found: %v", target)
+	}
+	if u.IsAbs() {
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Opaque != "" || u.User != nil {
+			return nil, fmt.Errorf("invalid url found: %v", target)
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return nil, fmt.Errorf("invalid url found: %v", target)
+	}
+	// URL.Query skips malformed parameters, so check them here
+	if _, err := url.QueryUnescape(u.RawQuery); err != nil {
+		return nil, fmt.Errorf("invalid query found: %v", u.RawQuery)
+	}
+	return u, nil
+}
+
 func parseRequestLine(line string) (string, string, string, string, error) {
 	fields := strings.SplitN(line, " ", 3)
 	if len(fields) != 3 {
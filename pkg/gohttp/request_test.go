@@ -2,6 +2,7 @@ package gohttp
 
 import (
 	"bufio"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -34,12 +35,14 @@ func TestReadGoodRequest(t *testing.T) {
 				"Host: test\r\n" +
 				"\r\n",
 			&Request{
-				Method: "GET",
-				URL:    "/index.html",
-				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
-				Host:   "test",
-				Close:  false,
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "test",
+				Close:      false,
+				Body:       NoBody,
 			},
 		},
 		{
@@ -49,12 +52,14 @@ func TestReadGoodRequest(t *testing.T) {
 				"Connection: close\r\n" +
 				"\r\n",
 			&Request{
-				Method: "GET",
-				URL:    "/index.html",
-				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
-				Host:   "test",
-				Close:  true,
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "test",
+				Close:      true,
+				Body:       NoBody,
 			},
 		},
 		{
@@ -66,15 +71,151 @@ func TestReadGoodRequest(t *testing.T) {
 				"Key2:   val2\r\n" +
 				"\r\n",
 			&Request{
-				Method: "GET",
-				URL:    "/index.html",
-				Proto:  "HTTP/1.1",
-				Header: map[string]string{
-					"Key1": "val1",
-					"Key2": "val2",
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.1",
+				Header: Header{
+					"Key1": {"val1"},
+					"Key2": {"val2"},
 				},
 				Host:  "test",
 				Close: true,
+				Body:  NoBody,
+			},
+		},
+		{
+			"RepeatedHeaders",
+			"GET /index.html HTTP/1.1\r\n" +
+				"Host: localhost:8080\r\n" +
+				"Accept: text/html\r\n" +
+				"X-Forwarded-For:\t192.0.2.1:1234 \r\n" +
+				"accept: application/json\r\n" +
+				"Empty:\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.1",
+				Header: Header{
+					"Accept":          {"text/html", "application/json"},
+					"X-Forwarded-For": {"192.0.2.1:1234"},
+					"Empty":           {""},
+				},
+				Host:  "localhost:8080",
+				Close: false,
+				Body:  NoBody,
+			},
+		},
+		{
+			"HTTP10",
+			"GET /index.html HTTP/1.0\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.0",
+				Header:     Header{},
+				Close:      true,
+				Body:       NoBody,
+			},
+		},
+		{
+			"HTTP10KeepAlive",
+			"GET /index.html HTTP/1.0\r\n" +
+				"Host: test\r\n" +
+				"Connection: Keep-Alive\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.0",
+				Header:     Header{},
+				Host:       "test",
+				Close:      false,
+				Body:       NoBody,
+			},
+		},
+		{
+			"Head",
+			"HEAD /index.html HTTP/1.1\r\n" +
+				"Host: test\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "HEAD",
+				URL:        &url.URL{Path: "/index.html"},
+				RequestURI: "/index.html",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "test",
+				Close:      false,
+				Body:       NoBody,
+			},
+		},
+		{
+			"OptionsServer",
+			"OPTIONS * HTTP/1.1\r\n" +
+				"Host: test\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "OPTIONS",
+				URL:        &url.URL{Path: "*"},
+				RequestURI: "*",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "test",
+				Close:      false,
+				Body:       NoBody,
+			},
+		},
+		{
+			"EscapesAndQuery",
+			"GET /a%20b/c%2Fd.html?v=2&q=a+b#top HTTP/1.1\r\n" +
+				"Host: test\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Path: "/a b/c/d.html", RawPath: "/a%20b/c%2Fd.html", RawQuery: "v=2&q=a+b"},
+				RequestURI: "/a%20b/c%2Fd.html?v=2&q=a+b#top",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "test",
+				Close:      false,
+				Body:       NoBody,
+			},
+		},
+		{
+			"AbsoluteURL",
+			"GET http://example.com:8080/index.html?v=2 HTTP/1.1\r\n" +
+				"Host: other\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Scheme: "http", Host: "example.com:8080", Path: "/index.html", RawQuery: "v=2"},
+				RequestURI: "http://example.com:8080/index.html?v=2",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "example.com:8080",
+				Close:      false,
+				Body:       NoBody,
+			},
+		},
+		{
+			"AbsoluteURLWithoutPath",
+			"GET https://example.com HTTP/1.1\r\n" +
+				"\r\n",
+			&Request{
+				Method:     "GET",
+				URL:        &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+				RequestURI: "https://example.com",
+				Proto:      "HTTP/1.1",
+				Header:     Header{},
+				Host:       "example.com",
+				Close:      false,
+				Body:       NoBody,
 			},
 		},
 	}
@@ -89,16 +230,89 @@ func TestReadGoodRequest(t *testing.T) {
 
 func TestReadBadRequest(t *testing.T) {
 	var tests = []struct {
-		name string
-		req  string
+		name       string
+		req        string
+		statusWant int
 	}{
 		{
 			"Basic",
 			"This is a bad request\r\n",
+			400,
 		},
 		{
 			"Empty",
 			"\r\n",
+			400,
+		},
+		{
+			"NoURL",
+			"GET  HTTP/1.1\r\n",
+			400,
+		},
+		{
+			"InvalidMethod",
+			"G(T /index.html HTTP/1.1\r\n",
+			400,
+		},
+		{
+			"UnknownMethod",
+			"BREW /index.html HTTP/1.1\r\n",
+			501,
+		},
+		{
+			"StarNotOptions",
+			"GET * HTTP/1.1\r\n",
+			400,
+		},
+		{
+			"MissingHost",
+			"GET /index.html HTTP/1.1\r\nConnection: close\r\n\r\n",
+			400,
+		},
+		{
+			"DuplicateHost",
+			"GET /index.html HTTP/1.1\r\nHost: a\r\nHost: b\r\n\r\n",
+			400,
+		},
+		{
+			"SpaceBeforeColon",
+			"GET /index.html HTTP/1.1\r\nHost : test\r\n\r\n",
+			400,
+		},
+		{
+			"ObsoleteLineFolding",
+			"GET /index.html HTTP/1.1\r\nHost: test\r\nX-Long: a\r\n b\r\n\r\n",
+			400,
+		},
+		{
+			"UnsupportedVersion",
+			"GET /index.html HTTP/2.0\r\nHost: test\r\n\r\n",
+			505,
+		},
+		{
+			"InvalidVersion",
+			"GET /index.html HTTP/1\r\nHost: test\r\n\r\n",
+			400,
+		},
+		{
+			"RelativeURL",
+			"GET index.html HTTP/1.1\r\nHost: test\r\n\r\n",
+			400,
+		},
+		{
+			"BadPathEscape",
+			"GET /a%zz.html HTTP/1.1\r\nHost: test\r\n\r\n",
+			400,
+		},
+		{
+			"BadQueryEscape",
+			"GET /index.html?q=%g0 HTTP/1.1\r\nHost: test\r\n\r\n",
+			400,
+		},
+		{
+			"AbsoluteURLBadScheme",
+			"GET ftp://test/index.html HTTP/1.1\r\nHost: test\r\n\r\n",
+			400,
 		},
 	}
 
@@ -106,6 +320,60 @@ func TestReadBadRequest(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			reqGot, _, err := ReadRequest(bufio.NewReader(strings.NewReader(tt.req)))
 			checkBadRequest(t, err, reqGot)
+			if status := errorStatus(err); status != tt.statusWant {
+				t.Fatalf("error status got: %v, want: %v", status, tt.statusWant)
+			}
+		})
+	}
+}
+
+func TestReadRequestLimits(t *testing.T) {
+	const maxHeaderBytes, maxURIBytes = 256, 64
+	var tests = []struct {
+		name       string
+		req        string
+		statusWant int // 0 for a good request
+	}{
+		{
+			"WithinLimits",
+			"GET /" + strings.Repeat("a", maxURIBytes-1) + " HTTP/1.1\r\nHost: test\r\n\r\n",
+			0,
+		},
+		{
+			"URITooLong",
+			"GET /" + strings.Repeat("a", maxURIBytes) + " HTTP/1.1\r\nHost: test\r\n\r\n",
+			414,
+		},
+		{
+			"RequestLineTooLong",
+			"GET /" + strings.Repeat("a", maxHeaderBytes) + " HTTP/1.1\r\nHost: test\r\n\r\n",
+			414,
+		},
+		{
+			"HeaderLineTooLong",
+			"GET / HTTP/1.1\r\nHost: test\r\nCookie: " + strings.Repeat("a", maxHeaderBytes) + "\r\n\r\n",
+			431,
+		},
+		{
+			"TooManyHeaders",
+			"GET / HTTP/1.1\r\nHost: test\r\n" + strings.Repeat("X-Header: 0123456789\r\n", maxHeaderBytes/20) + "\r\n",
+			431,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqGot, _, err := readRequest(bufio.NewReader(strings.NewReader(tt.req)), maxHeaderBytes, maxURIBytes)
+			if tt.statusWant == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			checkBadRequest(t, err, reqGot)
+			if status := errorStatus(err); status != tt.statusWant {
+				t.Fatalf("error status got: %v, want: %v", status, tt.statusWant)
+			}
 		})
 	}
 }
@@ -122,20 +390,24 @@ func TestReadMultipleRequests(t *testing.T) {
 				"GET /index.html HTTP/1.1\r\nHost: test\r\n\r\n",
 			[]*Request{
 				{
-					Method: "GET",
-					URL:    "/index.html",
-					Proto:  "HTTP/1.1",
-					Header: map[string]string{},
-					Host:   "test",
-					Close:  false,
+					Method:     "GET",
+					URL:        &url.URL{Path: "/index.html"},
+					RequestURI: "/index.html",
+					Proto:      "HTTP/1.1",
+					Header:     Header{},
+					Host:       "test",
+					Close:      false,
+					Body:       NoBody,
 				},
 				{
-					Method: "GET",
-					URL:    "/index.html",
-					Proto:  "HTTP/1.1",
-					Header: map[string]string{},
-					Host:   "test",
-					Close:  false,
+					Method:     "GET",
+					URL:        &url.URL{Path: "/index.html"},
+					RequestURI: "/index.html",
+					Proto:      "HTTP/1.1",
+					Header:     Header{},
+					Host:       "test",
+					Close:      false,
+					Body:       NoBody,
 				},
 			},
 		},
@@ -145,12 +417,14 @@ func TestReadMultipleRequests(t *testing.T) {
 				"GETT /index.html HTTP/1.1\r\nHost: test\r\n\r\n",
 			[]*Request{
 				{
-					Method: "GET",
-					URL:    "/index.html",
-					Proto:  "HTTP/1.1",
-					Header: map[string]string{},
-					Host:   "test",
-					Close:  false,
+					Method:     "GET",
+					URL:        &url.URL{Path: "/index.html"},
+					RequestURI: "/index.html",
+					Proto:      "HTTP/1.1",
+					Header:     Header{},
+					Host:       "test",
+					Close:      false,
+					Body:       NoBody,
 				},
 				nil,
 			},
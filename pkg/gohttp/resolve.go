@@ -0,0 +1,228 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A SymlinkPolicy says how a FileHandler treats symbolic links
+// found on the way to a requested file.
+type SymlinkPolicy int
+
+const (
+	// SymlinksFollow follows symbolic links, as long as their
+	// targets are under the root.
+	SymlinksFollow SymlinkPolicy = iota
+
+	// SymlinksOwnerMatch follows symbolic links whose targets are
+	// under the root and owned by the owner of the link.
+	SymlinksOwnerMatch
+
+	// SymlinksDeny refuses to follow any symbolic link.
+	SymlinksDeny
+)
+
+// ParseSymlinkPolicy returns the SymlinkPolicy with the given name:
+// "follow", "owner" or "deny".
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	switch strings.ToLower(name) {
+	case "follow":
+		return SymlinksFollow, nil
+	case "owner":
+		return SymlinksOwnerMatch, nil
+	case "deny":
+		return SymlinksDeny, nil
+	}
+	return 0, fmt.Errorf("unknown symlink policy: %q", name)
+}
+
+var (
+	// errBadPath means a URL path names no valid file.
+	errBadPath = errors.New("invalid URL path")
+
+	// errForbiddenPath means a path names a file that must not be
+	// served, which is answered as if the file didn't exist.
+	errForbiddenPath = errors.New("forbidden path")
+)
+
+// cleanPath cleans the decoded URL path p into a rooted path
+// without "." or ".." elements.
+func cleanPath(p string) (string, error) {
+	if strings.IndexByte(p, 0) >= 0 {
+		return "", errBadPath
+	}
+	return path.Clean("/" + p), nil
+}
+
+// hidden reports whether f hides files with the given name:
+// dotfiles unless f.ShowHidden is set, editor backup and swap
+// files, and names matching f.Hide.
+func (f *FileHandler) hidden(name string) bool {
+	if strings.HasPrefix(name, ".") && !f.ShowHidden && name != ".well-known" {
+		return true
+	}
+	if strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp") {
+		return true
+	}
+	for _, pattern := range f.Hide {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// root returns the absolute path of f.Root, with symbolic links
+// evaluated, so that resolved paths can be checked against it.
+func (f *FileHandler) root() (string, error) {
+	root, err := filepath.Abs(f.Root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(root)
+}
+
+// A sharedRoot is the os.Root of a FileHandler, shared by the requests
+// using it. Its Name is the path returned by FileHandler.root.
+type sharedRoot struct {
+	*os.Root
+	refs    int  // requests using the root
+	retired bool // replaced by the root of another directory
+}
+
+// acquireRoot returns f.Root opened as an os.Root, for a request to
+// use until it calls releaseRoot. The root is kept open for later
+// requests, until f.Root is found to resolve to another directory.
+// The previous root is then closed once the last request using it
+// releases it.
+func (f *FileHandler) acquireRoot() (*sharedRoot, error) {
+	root, err := f.root()
+	if err != nil {
+		return nil, err
+	}
+	f.rootMu.Lock()
+	defer f.rootMu.Unlock()
+	if f.osRoot == nil || f.osRoot.Name() != root {
+		r, err := os.OpenRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		if old := f.osRoot; old != nil {
+			old.retired = true
+			if old.refs == 0 {
+				old.Close()
+			}
+		}
+		f.osRoot = &sharedRoot{Root: r}
+	}
+	f.osRoot.refs++
+	return f.osRoot, nil
+}
+
+// releaseRoot tells f a request is done with r, from acquireRoot.
+func (f *FileHandler) releaseRoot(r *sharedRoot) {
+	f.rootMu.Lock()
+	defer f.rootMu.Unlock()
+	r.refs--
+	if r.retired && r.refs == 0 {
+		r.Close()
+	}
+}
+
+// rootName returns the name of the file at p, a path under the
+// directory of r, relative to r.
+func rootName(r *os.Root, p string) (string, error) {
+	return filepath.Rel(r.Name(), p)
+}
+
+// statIn returns the FileInfo of the file at p, a path under the
+// directory of r. The file is looked up through r, so that a symbolic
+// link changed since p was resolved can't lead outside of it.
+func statIn(r *os.Root, p string) (os.FileInfo, error) {
+	name, err := rootName(r, p)
+	if err != nil {
+		return nil, err
+	}
+	return r.Stat(name)
+}
+
+// resolve returns the path of the file named by name, a path from
+// cleanPath, under root. Each element of name is checked against
+// f.hidden, and symbolic links are followed according to f.Symlinks,
+// never leading outside root. If a file doesn't exist, the path it
+// would have is returned, for the caller to find out.
+func (f *FileHandler) resolve(root, name string) (string, error) {
+	p := root
+	for _, elem := range strings.Split(strings.TrimPrefix(name, "/"), "/") {
+		if elem == "" {
+			continue
+		}
+		if f.hidden(elem) {
+			return "", errForbiddenPath
+		}
+		var err error
+		if p, err = f.follow(root, filepath.Join(p, elem)); err != nil {
+			return "", err
+		}
+	}
+	return p, nil
+}
+
+// follow returns p, or the target of p if it is a symbolic link
+// that f.Symlinks allows following. The parent directory of p
+// must already be resolved under root. The target is checked
+// against f.hidden, as if it had been asked for by name.
+func (f *FileHandler) follow(root, p string) (string, error) {
+	fi, err := os.Lstat(p)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return p, nil
+	}
+	if f.Symlinks == SymlinksDeny {
+		return "", errForbiddenPath
+	}
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		// A dangling link is as good as a missing file
+		return p, nil
+	}
+	if !inRoot(root, target) {
+		return "", errForbiddenPath
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return "", errForbiddenPath
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+		if elem != "." && f.hidden(elem) {
+			return "", errForbiddenPath
+		}
+	}
+	if f.Symlinks == SymlinksOwnerMatch {
+		targetInfo, err := os.Stat(target)
+		if err != nil {
+			return "", errForbiddenPath
+		}
+		linkOwner, ok1 := fileOwner(fi)
+		targetOwner, ok2 := fileOwner(targetInfo)
+		if !ok1 || !ok2 || linkOwner != targetOwner {
+			return "", errForbiddenPath
+		}
+	}
+	return target, nil
+}
+
+// inRoot reports whether p is root or under it. Unlike a plain prefix
+// check, it doesn't mistake "/srv/www2" for being under "/srv/www".
+func inRoot(root, p string) bool {
+	if p == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(p, root)
+}
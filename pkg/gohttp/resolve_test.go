@@ -0,0 +1,210 @@
+package gohttp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeTree creates files under dir, mapping slash-separated names to
+// contents. Names ending in "/" are directories.
+func makeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(p, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileHandlerResolve(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "www")
+	makeTree(t, dir, map[string]string{
+		"www/index.html":               "index",
+		"www/docs/a.txt":               "a",
+		"www/.env":                     "secret",
+		"www/.well-known/security.txt": "contact",
+		"www/notes.txt~":               "backup",
+		"www/.notes.txt.swp":           "swap",
+		"www/notes.bak":                "backup",
+		"www/sub/.git/config":          "git",
+		"www2/secret.txt":              "sibling",
+		"outside.txt":                  "outside",
+	})
+	for link, target := range map[string]string{
+		"www/link.txt":   "docs/a.txt",
+		"www/linkdir":    "docs",
+		"www/escape.txt": "../outside.txt",
+		"www/escapedir":  "../www2",
+		"www/dangling":   "missing.txt",
+		"www/public":     "sub/.git",
+		"www/env.txt":    ".env",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name       string
+		url        string
+		symlinks   SymlinkPolicy
+		statusWant int
+	}{
+		{"File", "/docs/a.txt", SymlinksFollow, 200},
+		{"EncodedName", "/docs/%61.txt", SymlinksFollow, 200},
+		{"DotDot", "/../outside.txt", SymlinksFollow, 404},
+		{"EncodedDotDot", "/%2e%2e/outside.txt", SymlinksFollow, 404},
+		{"EncodedSlashDotDot", "/docs%2f..%2f..%2foutside.txt", SymlinksFollow, 404},
+		{"SiblingRoot", "/../www2/secret.txt", SymlinksFollow, 404},
+		{"NUL", "/docs/a.txt%00.html", SymlinksFollow, 400},
+		{"Dotfile", "/.env", SymlinksFollow, 404},
+		{"DotDir", "/sub/.git/config", SymlinksFollow, 404},
+		{"WellKnown", "/.well-known/security.txt", SymlinksFollow, 200},
+		{"Backup", "/notes.txt~", SymlinksFollow, 404},
+		{"Swap", "/.notes.txt.swp", SymlinksFollow, 404},
+		{"HideGlob", "/notes.bak", SymlinksFollow, 404},
+		{"Symlink", "/link.txt", SymlinksFollow, 200},
+		{"SymlinkDir", "/linkdir/a.txt", SymlinksFollow, 200},
+		{"SymlinkEscape", "/escape.txt", SymlinksFollow, 404},
+		{"SymlinkDirEscape", "/escapedir/secret.txt", SymlinksFollow, 404},
+		{"Dangling", "/dangling", SymlinksFollow, 404},
+		{"SymlinkToHiddenDir", "/public/config", SymlinksFollow, 404},
+		{"SymlinkToDotfile", "/env.txt", SymlinksFollow, 404},
+		{"OwnerMatch", "/link.txt", SymlinksOwnerMatch, 200},
+		{"OwnerMatchEscape", "/escape.txt", SymlinksOwnerMatch, 404},
+		{"Deny", "/link.txt", SymlinksDeny, 404},
+		{"DenyDir", "/linkdir/a.txt", SymlinksDeny, 404},
+		{"DenyPlainFile", "/docs/a.txt", SymlinksDeny, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &FileHandler{Root: root, Hide: []string{"*.bak"}, Symlinks: tt.symlinks}
+			req := &Request{Method: "GET", URL: mustParseURL(tt.url), Proto: "HTTP/1.1", Header: Header{}}
+			res := f.prepare(req)
+			if res.StatusCode != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", res.StatusCode, tt.statusWant)
+			}
+			if tt.statusWant == statusOK && !inRoot(root, res.FilePath) {
+				t.Fatalf("file path got: %q, want a file under %q", res.FilePath, root)
+			}
+		})
+	}
+}
+
+func TestFileHandlerSymlinkSwapped(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "www")
+	makeTree(t, dir, map[string]string{
+		"www/a.txt":   "a",
+		"outside.txt": "outside",
+	})
+	f := &FileHandler{Root: root}
+	req := &Request{Method: "GET", URL: mustParseURL("/a.txt"), Proto: "HTTP/1.1", Header: Header{}}
+	res := f.prepare(req)
+	if res.StatusCode != statusOK {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, statusOK)
+	}
+
+	// Replace the file resolved with a link leading outside the root
+	p := filepath.Join(root, "a.txt")
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../outside.txt", p); err != nil {
+		t.Fatal(err)
+	}
+	if rc, err := res.openBody(); err == nil {
+		rc.Close()
+		t.Fatal("file got opened through a link leading outside the root")
+	}
+}
+
+func TestFileHandlerSymlinkOwnerMismatch(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of a file needs root")
+	}
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"a.txt": "a"})
+	link := filepath.Join(root, "link.txt")
+	if err := os.Symlink("a.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Lchown(link, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &FileHandler{Root: root, Symlinks: SymlinksOwnerMatch}
+	req := &Request{Method: "GET", URL: mustParseURL("/link.txt"), Proto: "HTTP/1.1", Header: Header{}}
+	if res := f.prepare(req); res.StatusCode != statusNotFound {
+		t.Fatalf("status code got: %v, want: %v", res.StatusCode, statusNotFound)
+	}
+}
+
+func TestInRoot(t *testing.T) {
+	var tests = []struct {
+		root, p string
+		want    bool
+	}{
+		{"/srv/www", "/srv/www", true},
+		{"/srv/www", "/srv/www/index.html", true},
+		{"/srv/www", "/srv/www2/index.html", false},
+		{"/srv/www", "/srv", false},
+		{"/", "/etc/passwd", true},
+	}
+	for _, tt := range tests {
+		if got := inRoot(tt.root, tt.p); got != tt.want {
+			t.Errorf("inRoot(%q, %q) got: %v, want: %v", tt.root, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestFileHandlerRootReleased(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]string{"a/": "", "b/": ""})
+	link := filepath.Join(dir, "www")
+	if err := os.Symlink("a", link); err != nil {
+		t.Fatal(err)
+	}
+	f := &FileHandler{Root: link}
+	old, err := f.acquireRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Point the root elsewhere while a request still uses the old one
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b", link); err != nil {
+		t.Fatal(err)
+	}
+	r, err := f.acquireRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.releaseRoot(r)
+	if r == old || filepath.Base(r.Name()) != "b" {
+		t.Fatalf("root got: %q, want a new root for b", r.Name())
+	}
+	if _, err := old.Stat("."); err != nil {
+		t.Fatalf("old root got closed while in use: %v", err)
+	}
+	f.releaseRoot(old)
+	if _, err := old.Stat("."); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("old root Stat error got: %v, want: %v", err, os.ErrClosed)
+	}
+}
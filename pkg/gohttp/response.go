@@ -6,12 +6,37 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strconv"
 )
 
 var statusText = map[int]string{
+	100: "Continue",
+	103: "Early Hints",
 	200: "OK",
+	201: "Created",
+	204: "No Content",
+	206: "Partial Content",
+	301: "Moved Permanently",
+	302: "Found",
+	303: "See Other",
+	304: "Not Modified",
+	307: "Temporary Redirect",
+	308: "Permanent Redirect",
 	400: "Bad Request",
+	401: "Unauthorized",
 	404: "Not Found",
+	405: "Method Not Allowed",
+	409: "Conflict",
+	412: "Precondition Failed",
+	413: "Content Too Large",
+	414: "URI Too Long",
+	415: "Unsupported Media Type",
+	416: "Range Not Satisfiable",
+	417: "Expectation Failed",
+	431: "Request Header Fields Too Large",
+	500: "Internal Server Error",
+	501: "Not Implemented",
+	505: "HTTP Version Not Supported",
 }
 
 type Response struct {
@@ -19,9 +44,8 @@ type Response struct {
 	Proto      string // e.g. "HTTP/1.1"
 
 	// Header stores all headers to write to the response.
-	// Header keys are case-incensitive, and should be stored
-	// in the canonical format in this map.
-	Header map[string]string
+	// A header with several values is written as several lines.
+	Header Header
 
 	// Request is the valid request that leads to this response.
 	// It could be nil for responses not resulting from a valid request.
@@ -30,20 +54,49 @@ type Response struct {
 	// FilePath is the local path to the file to serve.
 	// It could be "", which means there is no file to serve.
 	FilePath string
+
+	// root, if not nil, is the root directory FilePath is under.
+	// The file is then opened through it, which fails if the
+	// file turns out to be outside of it.
+	root *os.Root
+
+	// Body is the content to serve when it doesn't come from a file.
+	// It is only used when FilePath is "". If Body is also an io.Closer,
+	// it is closed once the body is written.
+	Body io.Reader
 }
 
 // Write writes the res to the w.
+// The "Content-Length" header is set automatically if it is missing
+// and the size of the body is known. Otherwise the body is sent with
+// "Transfer-Encoding: chunked".
 func (res *Response) Write(w io.Writer) error {
+	chunked := false
+	if !res.Header.has("Content-Length") && bodyAllowed(res.StatusCode) {
+		if size := res.bodySize(); size >= 0 {
+			res.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+		} else {
+			res.Header.Set("Transfer-Encoding", "chunked")
+			chunked = true
+		}
+	}
 	if err := res.WriteStatusLine(w); err != nil {
 		return err
 	}
 	if err := res.WriteSortedHeaders(w); err != nil {
 		return err
 	}
-	if err := res.WriteBody(w); err != nil {
+	if res.isHead() {
+		return res.closeBody()
+	}
+	if !chunked {
+		return res.WriteBody(w)
+	}
+	cw := &chunkedWriter{w: w}
+	if err := res.WriteBody(cw); err != nil {
 		return err
 	}
-	return nil
+	return cw.Close()
 }
 
 // WriteStatusLine writes the status line of res to w, including the ending "\r\n".
@@ -72,16 +125,13 @@ func (res *Response) WriteSortedHeaders(w io.Writer) error {
 		sortedKeys = append(sortedKeys, k)
 	}
 	sort.Strings(sortedKeys)
-	fmt.Printf("sortedkey: %v\r\n", sortedKeys)
 	for _, k := range sortedKeys {
-		v, ok := res.Header[k]
-		if !ok {
-			continue
-		}
-		_, err := bw.WriteString(fmt.Sprintf("%v: %v\r\n", k, v))
-		fmt.Printf("%v: %v\r\n", k, v)
-		if err != nil {
-			return err
+		// Repeated headers are written in the order they were added
+		for _, v := range res.Header[k] {
+			_, err := bw.WriteString(fmt.Sprintf("%v: %v\r\n", k, v))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	bw.WriteString("\r\n")
@@ -91,19 +141,19 @@ func (res *Response) WriteSortedHeaders(w io.Writer) error {
 	return nil
 }
 
-// WriteBody writes res' file content as the response body to w.
-// It doesn't write anything if there is no file to serve.
+// WriteBody writes res' file content, or res.Body, as the response body to w.
+// It doesn't write anything if there is no body to serve.
 func (res *Response) WriteBody(w io.Writer) error {
 	bw := bufio.NewWriter(w)
-	if res.FilePath == "" {
-		return nil
-	}
-	f, err := os.Open(res.FilePath)
+	body, err := res.openBody()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = io.Copy(bw, f)
+	if body == nil {
+		return nil
+	}
+	defer body.Close()
+	_, err = io.Copy(bw, body)
 	if err != nil {
 		return err
 	}
@@ -112,3 +162,102 @@ func (res *Response) WriteBody(w io.Writer) error {
 	}
 	return nil
 }
+
+// Send writes res through w, the ResponseWriter of a handler.
+// This allows handlers to prepare a response with the HandleXXX methods
+// and then serve it like any other.
+func (res *Response) Send(w ResponseWriter) error {
+	header := w.Header()
+	for k, v := range res.Header {
+		header[k] = v
+	}
+	if !header.has("Content-Length") && bodyAllowed(res.StatusCode) {
+		if size := res.bodySize(); size >= 0 {
+			header.Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	if res.isHead() {
+		return res.closeBody()
+	}
+
+	body, err := res.openBody()
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return nil
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// isHead reports whether res answers a HEAD request,
+// in which case the body must not be written.
+func (res *Response) isHead() bool {
+	return res.Request != nil && res.Request.Method == "HEAD"
+}
+
+// closeBody closes res.Body, if it is an io.Closer,
+// for when the body won't be written.
+func (res *Response) closeBody() error {
+	if c, ok := res.Body.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// bodySize returns the size of the body of res in bytes,
+// or -1 if it can't be known before writing the body.
+func (res *Response) bodySize() int64 {
+	if res.FilePath != "" {
+		fi, err := res.statFile()
+		if err != nil {
+			return -1
+		}
+		return fi.Size()
+	}
+	if res.Body == nil {
+		return 0
+	}
+	if l, ok := res.Body.(interface{ Len() int }); ok {
+		return int64(l.Len())
+	}
+	return -1
+}
+
+// openBody returns a reader for the body of res, or nil if there is none.
+func (res *Response) openBody() (io.ReadCloser, error) {
+	if res.FilePath != "" {
+		return res.openFile()
+	}
+	if res.Body == nil {
+		return nil, nil
+	}
+	if rc, ok := res.Body.(io.ReadCloser); ok {
+		return rc, nil
+	}
+	return io.NopCloser(res.Body), nil
+}
+
+// openFile opens the file at res.FilePath, through res.root if set.
+func (res *Response) openFile() (*os.File, error) {
+	if res.root == nil {
+		return os.Open(res.FilePath)
+	}
+	name, err := rootName(res.root, res.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return res.root.Open(name)
+}
+
+// statFile returns the FileInfo of the file at res.FilePath,
+// through res.root if set.
+func (res *Response) statFile() (os.FileInfo, error) {
+	if res.root == nil {
+		return os.Stat(res.FilePath)
+	}
+	return statIn(res.root, res.FilePath)
+}
@@ -2,7 +2,9 @@ package gohttp
 
 import (
 	"bytes"
+	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -45,10 +47,10 @@ func TestWriteSortedHeaders(t *testing.T) {
 		{
 			"Basic",
 			&Response{
-				Header: map[string]string{
-					"Connection": "close",
-					"Date":       "foobar",
-					"Misc":       "hello world",
+				Header: Header{
+					"Connection": {"close"},
+					"Date":       {"foobar"},
+					"Misc":       {"hello world"},
 				},
 			},
 			"Connection: close\r\n" +
@@ -56,6 +58,19 @@ func TestWriteSortedHeaders(t *testing.T) {
 				"Misc: hello world\r\n" +
 				"\r\n",
 		},
+		{
+			"Repeated",
+			&Response{
+				Header: Header{
+					"Set-Cookie": {"b=2", "a=1"},
+					"Date":       {"foobar"},
+				},
+			},
+			"Date: foobar\r\n" +
+				"Set-Cookie: b=2\r\n" +
+				"Set-Cookie: a=1\r\n" +
+				"\r\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -123,3 +138,45 @@ func TestWriteBody(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteReaderBody(t *testing.T) {
+	res := &Response{
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		Header:     Header{},
+		Body:       strings.NewReader("hello, world"),
+	}
+	var buffer bytes.Buffer
+	if err := res.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	want := "HTTP/1.1 200 OK\r\n" +
+		"Content-Length: 12\r\n" +
+		"\r\n" +
+		"hello, world"
+	if got := buffer.String(); got != want {
+		t.Fatalf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestWriteChunkedBody(t *testing.T) {
+	res := &Response{
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		Header:     Header{},
+		// A reader with no Len() has a size unknown in advance
+		Body: io.MultiReader(strings.NewReader("hello, "), strings.NewReader("world")),
+	}
+	var buffer bytes.Buffer
+	if err := res.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	want := "HTTP/1.1 200 OK\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"c\r\nhello, world\r\n" +
+		"0\r\n\r\n"
+	if got := buffer.String(); got != want {
+		t.Fatalf("got: %q, want: %q", got, want)
+	}
+}
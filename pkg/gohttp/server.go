@@ -2,24 +2,61 @@ package gohttp
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
 const (
+	// responseProto is the version of all responses, including those
+	// to HTTP/1.0 requests, as it is the highest version the server
+	// conforms to (RFC 9110, Section 2.5).
 	responseProto = "HTTP/1.1"
 
-	statusOK         = 200
-	statusBadRequest = 400
-	statusNotFound   = 404
+	statusContinue                    = 100
+	statusOK                          = 200
+	statusCreated                     = 201
+	statusNoContent                   = 204
+	statusPartialContent              = 206
+	statusMovedPermanently            = 301
+	statusFound                       = 302
+	statusSeeOther                    = 303
+	statusNotModified                 = 304
+	statusTemporaryRedirect           = 307
+	statusPermanentRedirect           = 308
+	statusBadRequest                  = 400
+	statusNotFound                    = 404
+	statusMethodNotAllowed            = 405
+	statusConflict                    = 409
+	statusPreconditionFailed          = 412
+	statusContentTooLarge             = 413
+	statusURITooLong                  = 414
+	statusUnsupportedMediaType        = 415
+	statusRangeNotSatisfiable         = 416
+	statusExpectationFailed           = 417
+	statusRequestHeaderFieldsTooLarge = 431
+	statusInternalServerError         = 500
+	statusNotImplemented              = 501
+	statusHTTPVersionNotSupported     = 505
+
+	// defaultReadHeaderTimeout is how long clients get by default
+	// to send the request line and headers of a request.
+	defaultReadHeaderTimeout = 5 * time.Second
+
+	// defaultReadTimeout and defaultWriteTimeout are how long clients
+	// get by default to send the body of a request, and the server to
+	// send the response, so that a slow client can't hold on to a
+	// connection forever.
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 30 * time.Second
 )
 
 type Server struct {
@@ -29,40 +66,183 @@ type Server struct {
 	Addr string // e.g. ":0"
 
 	// DocRoot specifies the path to the directory to serve static files from.
+	// It is only required when Handler and VirtualHosts are not set.
 	DocRoot string
+
+	// Handler is invoked to respond to every valid request.
+	// If nil, a FileServer rooted at DocRoot is used.
+	Handler Handler
+
+	// VirtualHosts optionally maps host names, such as "example.com"
+	// or "*.example.com", to the handler for requests with a matching
+	// "Host" header, e.g. a FileServer for the document root of each
+	// site. Requests for other hosts go to Handler, or to DocRoot.
+	// If neither is set, they get 404 Not Found. See Hosts for how
+	// host names are matched.
+	VirtualHosts map[string]Handler
+
+	// Redirects lists paths redirected to other URLs,
+	// for every host, before the request reaches Handler.
+	Redirects []RedirectRule
+
+	// Compression, if not nil, enables compressing responses
+	// on the fly for clients that accept it.
+	Compression *Compression
+
+	// TLSConfig optionally provides a TLS configuration for use by
+	// ListenAndServeTLS. Use a CertStore as its GetCertificate to
+	// serve different certificates per hostname.
+	TLSConfig *tls.Config
+
+	// ReadHeaderTimeout is how long a client gets to send the request
+	// line and headers, once it has started sending a request.
+	// If zero, it is 5 seconds.
+	ReadHeaderTimeout time.Duration
+
+	// ReadTimeout is how long a client gets to send the body of a
+	// request, once the headers are read, including the part of the
+	// body the handler leaves unread. If zero, it is 30 seconds.
+	// If negative, there is no limit.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is how long the server gets to send a response,
+	// from the end of reading the request headers. If zero, it is
+	// 30 seconds. If negative, there is no limit.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is how long a keep-alive connection is kept open
+	// waiting for the next request. If zero, ReadHeaderTimeout is used.
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes limits the size of the request line and headers,
+	// line ends included. Larger requests get a 431 Request Header
+	// Fields Too Large response. If zero, it is 1MB.
+	MaxHeaderBytes int
+
+	// MaxURIBytes limits the length of the URL in the request line.
+	// Longer URLs get a 414 URI Too Long response. If zero, it is 8KB.
+	MaxURIBytes int
+
+	// AccessLog, if not nil, records each request the server
+	// responds to.
+	AccessLog *AccessLog
+
+	// Metrics, if not nil, collects statistics about the requests
+	// and connections of the server.
+	Metrics *Metrics
+
+	// MetricsPath, if not "", is the path at which the server answers
+	// with Metrics in the Prometheus text format, for any host.
+	MetricsPath string
+
+	// Logger receives debug output and errors, such as failures to
+	// write a response. If nil, nothing is logged.
+	Logger *slog.Logger
+
+	handlerOnce sync.Once
+	h           Handler  // built from the settings above by handler
+	allow       []string // the methods supported by h, see Server.methods
+
+	filesOnce sync.Once
+	files     *FileHandler // serving DocRoot, see Server.fileHandler
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]connState
+	inShutdown bool // set by Shutdown and Close
 }
 
 // ListenAndServe listens on the TCP network address s.Addr and then
-// handles requests on incoming connections.
+// calls Serve to handle requests on incoming connections.
+//
+// After Shutdown or Close, ListenAndServe returns ErrServerClosed.
 func (s *Server) ListenAndServe() error {
 	// Validate server configs
 	if err := s.ValidateServerSetup(); err != nil {
 		return fmt.Errorf("server is not setup correctly %v", err)
 	}
-	fmt.Println("Server setup valid!")
+	// Listen on a port
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.logger().Info("listening", "addr", ln.Addr())
+	return s.Serve(ln)
+}
 
+// ListenAndServeTLS listens on the TCP network address s.Addr and then
+// handles requests on incoming TLS connections.
+//
+// certFile and keyFile are PEM-encoded files holding the certificate
+// and its private key. They are reloaded when they change on disk.
+// They can be "" if s.TLSConfig already provides the certificates.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	// Validate server configs
+	if err := s.ValidateServerSetup(); err != nil {
+		return fmt.Errorf("server is not setup correctly %v", err)
+	}
+	config, err := s.tlsConfig(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("server is not setup correctly %v", err)
+	}
 	// Listen on a port
 	ln, err := net.Listen("tcp", s.Addr)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Listening on", ln.Addr())
+	s.logger().Info("listening with TLS", "addr", ln.Addr())
+	return s.Serve(tls.NewListener(ln, config))
+}
+
+// Serve accepts incoming connections on ln and handles requests on
+// them, until ln fails or the server is shut down. It closes ln
+// before returning.
+//
+// After Shutdown or Close, Serve returns ErrServerClosed.
+func (s *Server) Serve(ln net.Listener) error {
+	if err := s.ValidateServerSetup(); err != nil {
+		ln.Close()
+		return fmt.Errorf("server is not setup correctly %v", err)
+	}
+	if !s.trackListener(ln, true) {
+		ln.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(ln, false)
+	defer ln.Close()
 
-	// Accept connections and handle them
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			fmt.Printf("Error in accepting connection: %v", err)
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			s.logger().Error("failed to accept connection", "err", err)
 			continue
 		}
-		fmt.Printf("Accepted connection from %v", conn.RemoteAddr())
 		go s.HandleConnection(conn)
 	}
-	// Hint: call HandleConnection
 }
 
 func (s *Server) ValidateServerSetup() error {
+	for _, r := range s.Redirects {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	if s.Compression != nil {
+		if err := s.Compression.validate(); err != nil {
+			return err
+		}
+	}
+	if (s.Handler != nil || len(s.VirtualHosts) > 0) && s.DocRoot == "" {
+		return nil
+	}
 	fi, err := os.Stat(s.DocRoot)
 
 	if os.IsNotExist(err) {
@@ -77,150 +257,361 @@ func (s *Server) ValidateServerSetup() error {
 
 // HandleConnection reads requests from the accepted conn and handles them.
 func (s *Server) HandleConnection(conn net.Conn) {
-	fmt.Printf("Handling connection from %v\n", conn.RemoteAddr())
+	log := s.logger().With("remote_addr", conn.RemoteAddr().String())
+	log.Debug("handling connection")
 	defer conn.Close()
+	if !s.trackConn(conn, true) {
+		return
+	}
+	defer s.trackConn(conn, false)
 	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+
+	// depth counts the requests received in a row, that were sent
+	// without waiting for responses to the previous ones
+	depth := 0
+	if s.Metrics != nil {
+		defer func() {
+			if depth > 0 {
+				s.Metrics.observePipelineDepth(depth)
+			}
+		}()
+	}
 
 	for {
-		// Set a read timeout
-		if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
-			fmt.Printf("Failed to set timeout for the connection: %v", conn.RemoteAddr())
+		// Set the idle timeout, waiting for the next request
+		if err := conn.SetReadDeadline(time.Now().Add(s.idleTimeout())); err != nil {
+			log.Error("failed to set timeout for the connection", "err", err)
 			_ = conn.Close()
 			return
 		}
-		// Read the next request
-		req, bytesReceived, err := ReadRequest(br)
+		// Wait for the next request while idle, so that Shutdown
+		// can close the connection before a request starts
+		if br.Buffered() == 0 && depth > 0 {
+			if s.Metrics != nil {
+				s.Metrics.observePipelineDepth(depth)
+			}
+			depth = 0
+		}
+		if _, err := br.Peek(1); err != nil {
+			log.Debug("no more requests", "err", err)
+			if err, ok := err.(net.Error); ok && err.Timeout() && s.Metrics != nil {
+				s.Metrics.observeTimeout("empty")
+			}
+			return
+		}
+		depth++
+		if !s.setConnState(conn, stateActive) {
+			return
+		}
+		// Read the next request, within the header timeout
+		if err := conn.SetReadDeadline(time.Now().Add(s.readHeaderTimeout())); err != nil {
+			log.Error("failed to set timeout for the connection", "err", err)
+			return
+		}
+		req, bytesReceived, err := readRequest(br, s.maxHeaderBytes(), s.maxURIBytes())
+		if err := conn.SetWriteDeadline(deadline(s.writeTimeout())); err != nil {
+			log.Error("failed to set timeout for the connection", "err", err)
+			return
+		}
 
 		// Handle errors
 		// 1. Client closed connection => io.EOF error
 		if errors.Is(err, io.EOF) {
-			fmt.Printf("Client closed connection: %v", conn.RemoteAddr())
+			log.Debug("client closed connection")
 			_ = conn.Close()
 			return
 		}
 		// 2. Timeout from the server and no partial request is received.=> net.Error error
 		// TODO: require more work in proj3
 		if err, ok := err.(net.Error); ok && err.Timeout() && req == nil {
-			fmt.Printf("Timeout from the server and no partial request is received: %v", conn.RemoteAddr())
-			if bytesReceived {
-				res := &Response{
-					Header: make(map[string]string),
+			log.Debug("timeout reading request", "partial", bytesReceived)
+			if s.Metrics != nil {
+				if bytesReceived {
+					s.Metrics.observeTimeout("partial")
+				} else {
+					s.Metrics.observeTimeout("empty")
 				}
-				res.HandleBadRequest()
-				res.Write(conn)
+			}
+			if bytesReceived {
+				s.writeError(conn, statusBadRequest)
 			}
 			_ = conn.Close()
 			return
 		}
-		// 3. Handle for 400 response, close connection and return
+		// 3. Handle for 400 (or a more specific error) response, close connection and return
 		if err != nil {
-			fmt.Printf("Error in reading request: %v", err)
-			res := &Response{
-				Header: make(map[string]string),
-			}
-			res.HandleBadRequest()
-			res.Write(conn)
+			log.Debug("failed to read request", "err", err)
+			s.writeError(conn, errorStatus(err))
 			_ = conn.Close()
 			return
 		}
 		// 4. Handle the happy path (200 OK)
-		fmt.Printf("Handling good request for %v", req.URL)
-		// Handle good request
-		res := s.HandleGoodRequest(req)
-		fmt.Printf("filepath %s\n", res.FilePath)
-		// Write the response
-		if err := res.Write(conn); err != nil {
-			fmt.Printf("Failed to write response: %v", err)
-		}
-		// Close conn if requested
-		if req.Close {
+		log.Debug("handling request", "method", req.Method, "url", req.RequestURI)
+		req.RemoteAddr = conn.RemoteAddr().String()
+		req.log = s.logger()
+		// The handler reads the body within the read timeout
+		if err := conn.SetReadDeadline(deadline(s.readTimeout())); err != nil {
+			log.Error("failed to set timeout for the connection", "err", err)
+			return
+		}
+		// Handle good request and write the response
+		closeConn, err := s.serve(bw, req)
+		if err != nil {
+			log.Warn("failed to write response", "err", err)
+		}
+		// Close conn if requested, or if the server is shutting down
+		if closeConn || !s.setConnState(conn, stateIdle) {
 			_ = conn.Close()
+			return
 		}
 	}
 	// Hint: use the other methods below
 }
 
-// HandleGoodRequest handles the valid req and generates the corresponding res.
-func (s *Server) HandleGoodRequest(req *Request) (res *Response) {
-	res = &Response{
-		Header: make(map[string]string),
+// writeError writes an error response with statusCode to conn,
+// for a request that could not be read.
+func (s *Server) writeError(conn net.Conn, statusCode int) {
+	res := &Response{
+		Header: make(Header),
 	}
-	res.Proto = responseProto
-	res.StatusCode = statusOK
-	url := filepath.Clean(req.URL)
-	res.FilePath = path.Join(s.DocRoot, url) // TODO: handle path
-	// Hint: use the other methods below
+	res.HandleError(statusCode)
+	if err := res.Write(conn); err != nil {
+		s.logger().Debug("failed to write error response", "err", err)
+	}
+	if s.Metrics != nil {
+		s.Metrics.observeBadRequest(statusCode)
+	}
+	if s.AccessLog != nil {
+		s.logAccess(&accessRecord{
+			time:       time.Now(),
+			remoteAddr: conn.RemoteAddr().String(),
+			status:     statusCode,
+		})
+	}
+}
+
+func (s *Server) logAccess(rec *accessRecord) {
+	if err := s.AccessLog.log(rec); err != nil {
+		s.logger().Error("failed to write access log", "err", err)
+	}
+}
+
+func (s *Server) readHeaderTimeout() time.Duration {
+	if s.ReadHeaderTimeout > 0 {
+		return s.ReadHeaderTimeout
+	}
+	return defaultReadHeaderTimeout
+}
+
+func (s *Server) readTimeout() time.Duration {
+	if s.ReadTimeout == 0 {
+		return defaultReadTimeout
+	}
+	return s.ReadTimeout
+}
+
+func (s *Server) writeTimeout() time.Duration {
+	if s.WriteTimeout == 0 {
+		return defaultWriteTimeout
+	}
+	return s.WriteTimeout
+}
+
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+	return s.readHeaderTimeout()
+}
+
+func (s *Server) maxHeaderBytes() int {
+	if s.MaxHeaderBytes > 0 {
+		return s.MaxHeaderBytes
+	}
+	return defaultMaxHeaderBytes
+}
+
+func (s *Server) maxURIBytes() int {
+	if s.MaxURIBytes > 0 {
+		return s.MaxURIBytes
+	}
+	return defaultMaxURIBytes
+}
+
+// deadline returns the deadline for a timeout of d from now,
+// or the zero time, meaning no deadline, if d is not positive.
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// HandleGoodRequest handles the valid req by mapping it to a file
+// under s.DocRoot, and generates the corresponding res.
+func (s *Server) HandleGoodRequest(req *Request) (res *Response) {
+	return s.fileHandler().prepare(req)
+}
+
+// fileHandler returns the FileHandler serving s.DocRoot, created once
+// so that its root is opened once for all requests.
+func (s *Server) fileHandler() *FileHandler {
+	s.filesOnce.Do(func() {
+		s.files = &FileHandler{Root: s.DocRoot}
+	})
+	return s.files
+}
 
-	// Handle for 404 response (a valid request is received, and the requested file cannot be found or is not under the doc root.)
-	// Check if file exist
-	path, err := os.Stat(res.FilePath)
+// serve dispatches the valid req to the handler of s,
+// and writes the response to bw.
+// It reports whether the connection must be closed afterwards.
+func (s *Server) serve(bw *bufio.Writer, req *Request) (closeConn bool, err error) {
+	start := time.Now()
+	b, _ := req.Body.(*body)
+	w := newResponse(bw, req)
+	if b != nil && req.expectsContinue() {
+		w.expectContinue = true
+		b.onFirstRead = w.writeContinue
+	}
+	if req.URL.Path == "*" {
+		// "OPTIONS *" asks about the server rather than a resource
+		checkMethod(w, req, s.methods())
+	} else {
+		s.handler().ServeGoHTTP(w, req)
+	}
+	if req.MultipartForm != nil {
+		if err := req.MultipartForm.RemoveAll(); err != nil {
+			req.logger().Warn("failed to remove multipart form files", "err", err)
+		}
+	}
+	err = w.finish()
+	var bytesSent int64
+	if req.Method != "HEAD" {
+		bytesSent = w.written
+	}
+	duration := time.Since(start)
+	if s.AccessLog != nil {
+		rec := newAccessRecord(req, start)
+		rec.status = w.status
+		rec.bytes = bytesSent
+		rec.duration = duration
+		s.logAccess(rec)
+	}
+	if s.Metrics != nil {
+		s.Metrics.observeRequest(req.Method, w.status, bytesSent, duration)
+	}
 	if err != nil {
-		fmt.Printf("Error in checking if file exists: %v\n", err)
-		fmt.Printf("path: %v\n", path)
-		res.FilePath = ""
-		res.HandleNotFound(req)
-		return res
-		// Check if it's a folder, if so with /, add index.html, if not , return file not found
-	} else if path.IsDir() {
-		fmt.Printf("File is a directory: %v", res.FilePath)
-		if strings.HasSuffix(url, "/") {
-			res.FilePath = filepath.Join(res.FilePath, "index.html")
-		} else {
-			// file not found
-			res.FilePath = ""
-			res.HandleNotFound(req)
-			return res
+		return true, err
+	}
+	if w.closeAfter {
+		return true, nil
+	}
+	// Skip over the unread body to get to the next request
+	if b != nil {
+		if err := b.drain(); err != nil {
+			return true, err
 		}
 	}
+	return false, nil
+}
 
-	// Check if file is outside root
-	if !strings.HasPrefix(res.FilePath, s.DocRoot) {
-		fmt.Printf("File is outside root: %v", res.FilePath)
-		res.FilePath = ""
-		res.HandleNotFound(req)
-		return res
+// handler returns the handler serving the requests of s. It is built
+// from the settings of s once, when the first request is served.
+func (s *Server) handler() Handler {
+	s.handlerOnce.Do(func() {
+		s.h = s.buildHandler()
+	})
+	return s.h
+}
+
+// methods returns the methods supported by the handlers of s,
+// as listed in the response to "OPTIONS *".
+func (s *Server) methods() []string {
+	s.handler()
+	return s.allow
+}
+
+// buildHandler chains the handlers making up the settings of s.
+func (s *Server) buildHandler() Handler {
+	h := s.Handler
+	if h == nil {
+		if s.DocRoot != "" {
+			h = s.fileHandler()
+		} else {
+			h = NotFoundHandler()
+		}
+	}
+	if len(s.VirtualHosts) > 0 {
+		h = Hosts(s.VirtualHosts, h)
+	}
+	if len(s.Redirects) > 0 {
+		h = Redirects(h, s.Redirects)
 	}
-	// HandleOk
-	res.HandleOK(req, res.FilePath)
-	return res
+	// The handlers added below don't change the methods supported
+	s.allow = handlerMethods(h)
+	if s.Metrics != nil && s.MetricsPath != "" {
+		next := h
+		h = HandlerFunc(func(w ResponseWriter, req *Request) {
+			if req.URL.Path == s.MetricsPath {
+				s.Metrics.ServeGoHTTP(w, req)
+				return
+			}
+			next.ServeGoHTTP(w, req)
+		})
+	}
+	if s.Compression != nil {
+		h = Compress(h, s.Compression)
+	}
+	return h
 }
 
 // HandleOK prepares res to be a 200 OK response
 // ready to be written back to client.
 func (res *Response) HandleOK(req *Request, path string) {
-	stat, err := os.Stat(path)
-	res.Header["Date"] = FormatTime((time.Now()))
-	res.Header["Last-Modified"] = FormatTime(stat.ModTime())
-	res.Header["Content-Type"] = MIMETypeByExtension(filepath.Ext(path))
-	res.Header["Content-Length"] = strconv.Itoa(int(stat.Size()))
-	if req.Close {
-		res.Header["Connection"] = "close"
-	}
+	res.FilePath = path
+	stat, err := res.statFile()
 	if err != nil {
-		res.StatusCode = statusNotFound
+		res.FilePath = ""
+		res.HandleNotFound(req)
+		return
+	}
+	res.Header.Set("Date", FormatTime((time.Now())))
+	res.Header.Set("Last-Modified", FormatTime(stat.ModTime()))
+	res.Header.Set("Content-Type", MIMETypeByExtension(filepath.Ext(path)))
+	res.Header.Set("Content-Length", strconv.Itoa(int(stat.Size())))
+	if req.Close {
+		res.Header.Set("Connection", "close")
 	}
 	res.Proto = responseProto
 	res.StatusCode = statusOK
-	res.FilePath = path
 }
 
 // HandleBadRequest prepares res to be a 400 Bad Request response
 // ready to be written back to client.
 func (res *Response) HandleBadRequest() {
-	res.Header["Date"] = FormatTime((time.Now()))
+	res.Header.Set("Date", FormatTime((time.Now())))
 	res.Proto = responseProto
 	res.StatusCode = statusBadRequest
 	res.FilePath = ""
-	res.Header["Connection"] = "close"
+	res.Header.Set("Connection", "close")
+}
+
+// HandleError prepares res to be an error response with statusCode,
+// such as 501 Not Implemented, ready to be written back to client.
+// Like a 400 response, the connection is closed afterwards.
+func (res *Response) HandleError(statusCode int) {
+	res.HandleBadRequest()
+	res.StatusCode = statusCode
 }
 
 // HandleNotFound prepares res to be a 404 Not Found response
 // ready to be written back to client.
 func (res *Response) HandleNotFound(req *Request) {
-	res.Header["Date"] = FormatTime((time.Now()))
+	res.Header.Set("Date", FormatTime((time.Now())))
 	res.Proto = responseProto
 	res.StatusCode = statusNotFound
 	if req.Close {
-		res.Header["Connection"] = "close"
+		res.Header.Set("Connection", "close")
 	}
 }
@@ -1,8 +1,12 @@
 package gohttp
 
 import (
+	"io"
+	"net"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -40,9 +44,9 @@ func TestHandleGoodRequest(t *testing.T) {
 			"OKBasic",
 			&Request{
 				Method: "GET",
-				URL:    "/index.html",
+				URL:    mustParseURL("/index.html"),
 				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
+				Header: Header{},
 				Host:   "test",
 				Close:  false,
 			},
@@ -61,9 +65,9 @@ func TestHandleGoodRequest(t *testing.T) {
 			"OKClose",
 			&Request{
 				Method: "GET",
-				URL:    "/index.html",
+				URL:    mustParseURL("/index.html"),
 				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
+				Header: Header{},
 				Host:   "test",
 				Close:  true,
 			},
@@ -83,9 +87,30 @@ func TestHandleGoodRequest(t *testing.T) {
 			"OKDefaultRoot",
 			&Request{
 				Method: "GET",
-				URL:    "/",
+				URL:    mustParseURL("/"),
 				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
+				Header: Header{},
+				Host:   "test",
+				Close:  false,
+			},
+			200,
+			[]string{
+				"Date",
+				"Last-Modified",
+			},
+			map[string]string{
+				"Content-Type":   contentTypeHTML,
+				"Content-Length": "12",
+			},
+			"index.html",
+		},
+		{
+			"OKQuery",
+			&Request{
+				Method: "GET",
+				URL:    mustParseURL("/%69ndex.html?v=2"),
+				Proto:  "HTTP/1.1",
+				Header: Header{},
 				Host:   "test",
 				Close:  false,
 			},
@@ -104,9 +129,9 @@ func TestHandleGoodRequest(t *testing.T) {
 			"NotFoundBasic",
 			&Request{
 				Method: "GET",
-				URL:    "/notexist.html",
+				URL:    mustParseURL("/notexist.html"),
 				Proto:  "HTTP/1.1",
-				Header: map[string]string{},
+				Header: Header{},
 				Host:   "test",
 				Close:  false,
 			},
@@ -130,16 +155,15 @@ func TestHandleGoodRequest(t *testing.T) {
 				t.Fatalf("status code got: %v, want: %v", res.StatusCode, tt.statusWant)
 			}
 			for _, h := range tt.headersWant {
-				if _, ok := res.Header[h]; !ok {
+				if !res.Header.has(h) {
 					t.Fatalf("missing header %q", h)
 				}
 			}
 			for h, vWant := range tt.headerValuesWant {
-				v, ok := res.Header[h]
-				if !ok {
+				if !res.Header.has(h) {
 					t.Fatalf("missing header %q", h)
 				}
-				if v != vWant {
+				if v := res.Header.Get(h); v != vWant {
 					t.Fatalf("header %q value got: %q, want %q", h, v, vWant)
 				}
 			}
@@ -161,3 +185,319 @@ func TestHandleGoodRequest(t *testing.T) {
 		})
 	}
 }
+
+// roundTrip sends reqText to s over an in-memory connection,
+// and returns everything s writes back until it closes the connection.
+func roundTrip(t *testing.T, s *Server, reqText string) string {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go s.HandleConnection(serverConn)
+	go func() {
+		_, _ = io.WriteString(clientConn, reqText)
+	}()
+	if err := clientConn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	resBytes, err := io.ReadAll(clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(resBytes)
+}
+
+func TestServerHandler(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/", FileServer("testdata"))
+	mux.HandleFunc("/hello", func(w ResponseWriter, req *Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello, world")
+	})
+	s := &Server{
+		Addr:    ":0",
+		Handler: mux,
+	}
+	if err := s.ValidateServerSetup(); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name         string
+		reqText      string
+		resWantParts []string
+	}{
+		{
+			"Dynamic",
+			"GET /hello HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\n",
+				"Connection: close\r\nContent-Length: 12\r\nContent-Type: text/plain\r\nDate: ",
+				"\r\n\r\nhello, world",
+			},
+		},
+		{
+			"Static",
+			"GET /index.html HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\n",
+				"Content-Length: 12\r\nContent-Type: text/html; charset=utf-8\r\n",
+			},
+		},
+		{
+			"Pipelined",
+			"GET /hello HTTP/1.1\r\nHost: test\r\n\r\n" +
+				"GET /missing HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\n",
+				"\r\n\r\nhello, world",
+				"HTTP/1.1 404 Not Found\r\nConnection: close\r\nContent-Length: 0\r\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resText := roundTrip(t, s, tt.reqText)
+			rest := resText
+			for _, part := range tt.resWantParts {
+				i := strings.Index(rest, part)
+				if i < 0 {
+					t.Fatalf("response is missing %q\ngot: %q", part, resText)
+				}
+				rest = rest[i+len(part):]
+			}
+		})
+	}
+}
+
+func TestServerMethods(t *testing.T) {
+	s := &Server{
+		Addr:    ":0",
+		DocRoot: "testdata",
+	}
+
+	var tests = []struct {
+		name     string
+		reqText  string
+		resWant  string // the response up to and excluding the "Date" header
+		bodyWant string
+	}{
+		{
+			"Head",
+			"HEAD /index.html HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			"HTTP/1.1 200 OK\r\n" +
+				"Accept-Ranges: bytes\r\n" +
+				"Connection: close\r\n" +
+				"Content-Length: 12\r\n" +
+				"Content-Type: text/html; charset=utf-8\r\n",
+			"",
+		},
+		{
+			"Options",
+			"OPTIONS /index.html HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			"HTTP/1.1 200 OK\r\n" +
+				"Allow: GET, HEAD, OPTIONS\r\n" +
+				"Connection: close\r\n" +
+				"Content-Length: 0\r\n",
+			"",
+		},
+		{
+			"OptionsServer",
+			"OPTIONS * HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			"HTTP/1.1 200 OK\r\n" +
+				"Allow: GET, HEAD, OPTIONS\r\n" +
+				"Connection: close\r\n" +
+				"Content-Length: 0\r\n",
+			"",
+		},
+		{
+			"MethodNotAllowed",
+			"DELETE /index.html HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n",
+			"HTTP/1.1 405 Method Not Allowed\r\n" +
+				"Allow: GET, HEAD, OPTIONS\r\n" +
+				"Connection: close\r\n" +
+				"Content-Length: 0\r\n",
+			"",
+		},
+		{
+			"NotImplemented",
+			"BREW /index.html HTTP/1.1\r\nHost: test\r\n\r\n",
+			"HTTP/1.1 501 Not Implemented\r\n" +
+				"Connection: close\r\n" +
+				"Content-Length: 0\r\n",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resText := roundTrip(t, s, tt.reqText)
+			if !strings.HasPrefix(resText, tt.resWant+"Date: ") {
+				t.Fatalf("\ngot: %q\nwant prefix: %q", resText, tt.resWant)
+			}
+			i := strings.Index(resText, "\r\n\r\n")
+			if body := resText[i+4:]; body != tt.bodyWant {
+				t.Fatalf("body got: %q, want: %q", body, tt.bodyWant)
+			}
+		})
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	uploads := NewServeMux()
+	uploads.Handle("/", FileServer("testdata"))
+	uploads.Handle("/uploads", &UploadHandler{Dir: t.TempDir()})
+
+	var tests = []struct {
+		name      string
+		handler   Handler
+		vhosts    map[string]Handler
+		allowWant string
+	}{
+		{"Files", FileServer("testdata"), nil, "GET, HEAD, OPTIONS"},
+		{"Writable", &FileHandler{Root: "testdata", Writable: []string{"/up"}}, nil, "DELETE, GET, HEAD, PUT, OPTIONS"},
+		{"Upload", uploads, nil, "GET, HEAD, POST, OPTIONS"},
+		{"Methods", Methods(NotFoundHandler(), "POST"), nil, "POST, OPTIONS"},
+		{"VirtualHosts", Methods(NotFoundHandler(), "POST"), map[string]Handler{"*.example.com": FileServer("testdata")}, "GET, HEAD, POST, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Addr: ":0", Handler: tt.handler, VirtualHosts: tt.vhosts}
+			resText := roundTrip(t, s, "OPTIONS * HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+			if want := "HTTP/1.1 200 OK\r\nAllow: " + tt.allowWant + "\r\n"; !strings.HasPrefix(resText, want) {
+				t.Fatalf("\ngot: %q\nwant prefix: %q", resText, want)
+			}
+		})
+	}
+}
+
+func TestServerHTTP10(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/hello", func(w ResponseWriter, req *Request) {
+		io.WriteString(w, "hello")
+	})
+	mux.HandleFunc("/stream", func(w ResponseWriter, req *Request) {
+		io.WriteString(w, "hello, ")
+		w.(Flusher).Flush()
+		io.WriteString(w, "world")
+	})
+	s := &Server{Addr: ":0", Handler: mux}
+
+	var tests = []struct {
+		name          string
+		reqText       string
+		responsesWant []string // each response, without its "Date" header
+	}{
+		{
+			"CloseByDefault",
+			"GET /hello HTTP/1.0\r\n\r\n" +
+				"GET /hello HTTP/1.0\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n\r\nhello",
+			},
+		},
+		{
+			"KeepAlive",
+			"GET /hello HTTP/1.0\r\nConnection: keep-alive\r\n\r\n" +
+				"GET /hello HTTP/1.0\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\nConnection: keep-alive\r\nContent-Length: 5\r\n\r\nhello",
+				"HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 5\r\n\r\nhello",
+			},
+		},
+		{
+			"UnknownLength",
+			"GET /stream HTTP/1.0\r\nConnection: keep-alive\r\n\r\n" +
+				"GET /hello HTTP/1.0\r\n\r\n",
+			[]string{
+				"HTTP/1.1 200 OK\r\nConnection: close\r\n\r\nhello, world",
+			},
+		},
+		{
+			"UnsupportedVersion",
+			"GET /hello HTTP/2.0\r\nHost: test\r\n\r\n",
+			[]string{
+				"HTTP/1.1 505 HTTP Version Not Supported\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resText := roundTrip(t, s, tt.reqText)
+			// Leave out the "Date" headers, which vary
+			var lines []string
+			for _, line := range strings.SplitAfter(resText, "\r\n") {
+				if !strings.HasPrefix(line, "Date: ") {
+					lines = append(lines, line)
+				}
+			}
+			if got, want := strings.Join(lines, ""), strings.Join(tt.responsesWant, ""); got != want {
+				t.Fatalf("\ngot: %q\nwant: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestServerTimeoutDefaults(t *testing.T) {
+	s := &Server{}
+	if s.readTimeout() != defaultReadTimeout || s.writeTimeout() != defaultWriteTimeout {
+		t.Fatalf("timeouts got: %v, %v, want: %v, %v", s.readTimeout(), s.writeTimeout(), defaultReadTimeout, defaultWriteTimeout)
+	}
+	s = &Server{ReadTimeout: -1, WriteTimeout: -1}
+	if !deadline(s.readTimeout()).IsZero() || !deadline(s.writeTimeout()).IsZero() {
+		t.Fatal("negative timeouts got a deadline, want none")
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	s := &Server{
+		Addr:              ":0",
+		DocRoot:           "testdata",
+		ReadHeaderTimeout: timeout,
+		IdleTimeout:       timeout,
+		ReadTimeout:       timeout,
+		WriteTimeout:      timeout,
+		MaxHeaderBytes:    64,
+	}
+
+	var tests = []struct {
+		name    string
+		reqText string
+		resWant string // the beginning of the response
+	}{
+		{"Idle", "", ""},
+		{"IdleAfterRequest", "HEAD /index.html HTTP/1.1\r\nHost: test\r\n\r\n", "HTTP/1.1 200 OK\r\n"},
+		{"PartialRequest", "GET /index.html HTTP/1.1\r\nHost", "HTTP/1.1 400 Bad Request\r\n"},
+		{"HeadersTooLarge", "GET /index.html HTTP/1.1\r\nHost: test\r\nCookie: " + strings.Repeat("a", 64) + "\r\n\r\n", "HTTP/1.1 431 Request Header Fields Too Large\r\n"},
+		{"SlowBody", "GET /index.html HTTP/1.1\r\nHost: test\r\nContent-Length: 10\r\n\r\nab", "HTTP/1.1 200 OK\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resGot := roundTrip(t, s, tt.reqText)
+			if !strings.HasPrefix(resGot, tt.resWant) {
+				t.Fatalf("response got: %q, want it to start with: %q", resGot, tt.resWant)
+			}
+		})
+	}
+
+	t.Run("SlowReader", func(t *testing.T) {
+		// The client never reads the response, so writing it times out
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		done := make(chan struct{})
+		go func() {
+			s.HandleConnection(serverConn)
+			close(done)
+		}()
+		io.WriteString(clientConn, "GET /index.html HTTP/1.1\r\nHost: test\r\n\r\n")
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("connection still open after the write timeout")
+		}
+	})
+}
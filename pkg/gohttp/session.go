@@ -0,0 +1,457 @@
+package gohttp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSessionCookie is the default name of the session cookie.
+	defaultSessionCookie = "session"
+
+	// defaultSessionMaxAge is how long sessions last by default
+	// after they were last changed.
+	defaultSessionMaxAge = 24 * time.Hour
+
+	// minSessionKeyBytes is the shortest key to sign session IDs with,
+	// the size of a SHA-256 hash.
+	minSessionKeyBytes = 32
+
+	// sessionSweepInterval is how often a MemoryStore
+	// drops its expired sessions.
+	sessionSweepInterval = time.Minute
+)
+
+// ErrNoSession is returned by a SessionStore loading a session
+// that doesn't exist or has expired.
+var ErrNoSession = errors.New("gohttp: session not found")
+
+// A SessionStore keeps the values of sessions by ID.
+// Its methods may be called concurrently.
+type SessionStore interface {
+	// Load returns the values of the session with id,
+	// or ErrNoSession if there is none or it has expired.
+	Load(id string) (map[string]string, error)
+
+	// Save stores the values of the session with id, which
+	// expires at expires, replacing any it had before.
+	Save(id string, values map[string]string, expires time.Time) error
+
+	// Delete removes the session with id, if any.
+	Delete(id string) error
+}
+
+// SessionConfig configures the sessions of a Sessions handler.
+type SessionConfig struct {
+	// Store keeps the values of the sessions.
+	Store SessionStore
+
+	// Key signs the session IDs sent in cookies, so that forged IDs
+	// are rejected before reaching Store. It must be at least 32
+	// random bytes, and changing it ends all sessions.
+	Key []byte
+
+	// CookieName is the name of the session cookie.
+	// If empty, "session" is used.
+	CookieName string
+
+	// MaxAge is how long a session lasts after it was last changed.
+	// If zero, defaultSessionMaxAge is used.
+	MaxAge time.Duration
+
+	// Path is the "Path" of the session cookie. If empty, "/" is used.
+	Path string
+
+	// Secure only lets the session cookie be sent over HTTPS.
+	Secure bool
+
+	// SameSite is the "SameSite" attribute of the session cookie.
+	// If SameSiteDefault, Lax is used.
+	SameSite SameSite
+}
+
+func (c *SessionConfig) cookieName() string {
+	if c.CookieName != "" {
+		return c.CookieName
+	}
+	return defaultSessionCookie
+}
+
+func (c *SessionConfig) maxAge() time.Duration {
+	if c.MaxAge > 0 {
+		return c.MaxAge
+	}
+	return defaultSessionMaxAge
+}
+
+// sign returns the value of the session cookie for id:
+// id and its HMAC-SHA256 signature, separated by a dot.
+func (c *SessionConfig) sign(id string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the session ID of the cookie value v,
+// if its signature is valid.
+func (c *SessionConfig) verify(v string) (string, bool) {
+	id, _, ok := strings.Cut(v, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, hmac.Equal([]byte(c.sign(id)), []byte(v))
+}
+
+// A Session holds the values kept for a client across requests, such
+// as the user logged in. Handlers wrapped by Sessions get the session
+// of a request with Request.Session. A session is only stored, and its
+// cookie only sent, once a value is set.
+type Session struct {
+	id      string // "" until the session is stored
+	values  map[string]string
+	changed bool
+	renew   bool
+}
+
+// ID returns the ID of s, or "" if s is not stored yet.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Get returns the value of key, or "" if there is none.
+func (s *Session) Get(key string) string {
+	return s.values[key]
+}
+
+// Set sets the value of key.
+func (s *Session) Set(key, value string) {
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete deletes the value of key.
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// RenewID gives s a new ID, keeping its values, so that an ID
+// known before, e.g. before logging in, can't be used to take
+// over the session.
+func (s *Session) RenewID() {
+	s.renew = true
+	s.changed = true
+}
+
+// Destroy deletes all values of s, and removes it from the store
+// along with the cookie of the client.
+func (s *Session) Destroy() {
+	s.values = make(map[string]string)
+	s.RenewID()
+}
+
+// Session returns the session of req, or nil if the handler
+// isn't wrapped by Sessions.
+func (req *Request) Session() *Session {
+	return req.session
+}
+
+// Sessions returns a handler that gives each request to h a Session,
+// identified by a signed cookie and stored as configured by c.
+// Changes h makes to the session are saved when the response
+// headers are sent, so later ones are lost. It panics if c.Key is
+// shorter than 32 bytes.
+func Sessions(h Handler, c *SessionConfig) Handler {
+	if len(c.Key) < minSessionKeyBytes {
+		panic("gohttp: session key shorter than 32 bytes")
+	}
+	return HandlerFunc(func(w ResponseWriter, req *Request) {
+		sess := &Session{values: make(map[string]string)}
+		hadCookie := false
+		if cookie, err := req.Cookie(c.cookieName()); err == nil {
+			hadCookie = true
+			if id, ok := c.verify(cookie.Value); !ok {
+				req.logger().Debug("invalid session cookie signature")
+			} else if values, err := c.Store.Load(id); err == nil {
+				sess.id, sess.values = id, values
+			} else if !errors.Is(err, ErrNoSession) {
+				req.logger().Warn("failed to load session", "err", err)
+			}
+		}
+		req.session = sess
+		sw := &sessionWriter{w: w, req: req, c: c, hadCookie: hadCookie}
+		h.ServeGoHTTP(sw, req)
+		sw.commit()
+	})
+}
+
+// sessionWriter is the ResponseWriter of a Sessions handler. It saves
+// the session and sets its cookie before the response headers are sent.
+type sessionWriter struct {
+	w         ResponseWriter
+	req       *Request
+	c         *SessionConfig
+	hadCookie bool // whether the client sent a session cookie
+	committed bool
+}
+
+func (sw *sessionWriter) Header() Header {
+	return sw.w.Header()
+}
+
+func (sw *sessionWriter) WriteHeader(statusCode int) {
+	if !isInterim(statusCode) {
+		sw.commit()
+	}
+	sw.w.WriteHeader(statusCode)
+}
+
+func (sw *sessionWriter) Write(p []byte) (int, error) {
+	sw.commit()
+	return sw.w.Write(p)
+}
+
+func (sw *sessionWriter) Flush() {
+	sw.commit()
+	if f, ok := sw.w.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// commit saves the session of the request, if changed, and sets the
+// session cookie accordingly. Only the first call does anything.
+func (sw *sessionWriter) commit() {
+	if sw.committed {
+		return
+	}
+	sw.committed = true
+	sess := sw.req.session
+	if !sess.changed {
+		return
+	}
+	store := sw.c.Store
+	if sess.renew && sess.id != "" {
+		if err := store.Delete(sess.id); err != nil {
+			sw.req.logger().Warn("failed to delete session", "err", err)
+		}
+		sess.id = ""
+	}
+	cookie := &Cookie{
+		Name:     sw.c.cookieName(),
+		Path:     sw.c.Path,
+		Secure:   sw.c.Secure,
+		HttpOnly: true,
+		SameSite: sw.c.SameSite,
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == SameSiteDefault {
+		cookie.SameSite = SameSiteLax
+	}
+
+	if len(sess.values) == 0 {
+		// An empty session is not worth storing
+		if sess.id != "" {
+			if err := store.Delete(sess.id); err != nil {
+				sw.req.logger().Warn("failed to delete session", "err", err)
+			}
+			sess.id = ""
+		}
+		if sw.hadCookie {
+			cookie.MaxAge = -1
+			SetCookie(sw.w, cookie)
+		}
+		return
+	}
+	if sess.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			sw.req.logger().Warn("failed to generate session ID", "err", err)
+			return
+		}
+		sess.id = id
+	}
+	maxAge := sw.c.maxAge()
+	if err := store.Save(sess.id, sess.values, time.Now().Add(maxAge)); err != nil {
+		sw.req.logger().Warn("failed to save session", "err", err)
+		return
+	}
+	cookie.Value = sw.c.sign(sess.id)
+	cookie.MaxAge = int(maxAge / time.Second)
+	SetCookie(sw.w, cookie)
+}
+
+// newSessionID returns a new random session ID.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validSessionID reports whether id looks like an ID from newSessionID,
+// so that it can safely be used as a file name.
+func validSessionID(id string) bool {
+	return id != "" && strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) == -1
+}
+
+// MemoryStore is a SessionStore keeping sessions in memory.
+// They are lost when the server stops.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]storedSession
+	lastSweep time.Time
+}
+
+// storedSession is a session as kept by a store.
+type storedSession struct {
+	Values  map[string]string `json:"values"`
+	Expires time.Time         `json:"expires"`
+}
+
+// NewMemoryStore allocates and returns a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]storedSession),
+	}
+}
+
+func (ms *MemoryStore) Load(id string) (map[string]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	s, ok := ms.sessions[id]
+	if !ok || !time.Now().Before(s.Expires) {
+		return nil, ErrNoSession
+	}
+	return copyValues(s.Values), nil
+}
+
+func (ms *MemoryStore) Save(id string, values map[string]string, expires time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now()
+	if now.Sub(ms.lastSweep) >= sessionSweepInterval {
+		for id, s := range ms.sessions {
+			if !now.Before(s.Expires) {
+				delete(ms.sessions, id)
+			}
+		}
+		ms.lastSweep = now
+	}
+	ms.sessions[id] = storedSession{copyValues(values), expires}
+	return nil
+}
+
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, id)
+	return nil
+}
+
+func copyValues(values map[string]string) map[string]string {
+	c := make(map[string]string, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
+
+// FileStore is a SessionStore keeping each session in a JSON file
+// named after its ID in a directory, so that sessions survive
+// restarts of the server. Expired sessions are removed when loaded.
+type FileStore struct {
+	// Dir is the directory of the session files. It is created
+	// if missing, and should not be readable by other users.
+	Dir string
+}
+
+// NewFileStore returns a FileStore keeping sessions in dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+func (fs *FileStore) path(id string) (string, error) {
+	if !validSessionID(id) {
+		return "", ErrNoSession
+	}
+	return filepath.Join(fs.Dir, id+".json"), nil
+}
+
+func (fs *FileStore) Load(id string) (map[string]string, error) {
+	p, err := fs.path(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s storedSession
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(s.Expires) {
+		os.Remove(p)
+		return nil, ErrNoSession
+	}
+	return s.Values, nil
+}
+
+// Save writes the session to a temporary file first, which is then
+// renamed, so that a session is never read half written.
+func (fs *FileStore) Save(id string, values map[string]string, expires time.Time) error {
+	p, err := fs.path(id)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(storedSession{values, expires})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.Dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(fs.Dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (fs *FileStore) Delete(id string) error {
+	p, err := fs.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package gohttp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSessionKey = []byte("0123456789abcdef0123456789abcdef")
+
+// sessionRoundTrip serves a request with the cookie header through h,
+// and returns the response recorded.
+func sessionRoundTrip(h Handler, cookie string) *recorder {
+	req := writeRequest("GET", "/", "", false)
+	if cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+	w := newRecorder()
+	h.ServeGoHTTP(w, req)
+	return w
+}
+
+// sessionCookie returns the "name=value" part of the session cookie
+// set in the response recorded by w, or "" if none was set.
+func sessionCookie(w *recorder) string {
+	for _, v := range w.header.Values("Set-Cookie") {
+		if strings.HasPrefix(v, defaultSessionCookie+"=") {
+			nameValue, _, _ := strings.Cut(v, ";")
+			return nameValue
+		}
+	}
+	return ""
+}
+
+func TestSessions(t *testing.T) {
+	store := NewMemoryStore()
+	var action string
+	h := Sessions(HandlerFunc(func(w ResponseWriter, req *Request) {
+		sess := req.Session()
+		switch action {
+		case "login":
+			sess.Set("user", "alice")
+			sess.RenewID()
+		case "destroy":
+			sess.Destroy()
+		}
+		io.WriteString(w, sess.Get("user"))
+	}), &SessionConfig{Store: store, Key: testSessionKey})
+
+	// No session is stored for a visitor without values
+	w := sessionRoundTrip(h, "")
+	if got := w.header.Values("Set-Cookie"); len(got) != 0 {
+		t.Fatalf("Set-Cookie got: %q, want none", got)
+	}
+
+	action = "login"
+	w = sessionRoundTrip(h, "")
+	setCookie := w.header.Get("Set-Cookie")
+	if !strings.Contains(setCookie, "; Max-Age=86400; HttpOnly; SameSite=Lax") || !strings.Contains(setCookie, "; Path=/") {
+		t.Fatalf("Set-Cookie got: %q, want the default attributes", setCookie)
+	}
+	cookie := sessionCookie(w)
+
+	action = ""
+	w = sessionRoundTrip(h, cookie)
+	if w.body.String() != "alice" {
+		t.Fatalf("body got: %q, want: %q", w.body.String(), "alice")
+	}
+	if got := w.header.Values("Set-Cookie"); len(got) != 0 {
+		t.Fatalf("Set-Cookie got: %q, want none for an unchanged session", got)
+	}
+
+	// Logging in again renews the ID, and the old one stops working
+	action = "login"
+	renewed := sessionCookie(sessionRoundTrip(h, cookie))
+	if renewed == "" || renewed == cookie {
+		t.Fatalf("renewed cookie got: %q, want a new one", renewed)
+	}
+	action = ""
+	if w := sessionRoundTrip(h, cookie); w.body.String() != "" {
+		t.Fatalf("body with the old cookie got: %q, want none", w.body.String())
+	}
+
+	// A forged signature is rejected
+	id, _, _ := strings.Cut(strings.TrimPrefix(renewed, defaultSessionCookie+"="), ".")
+	if w := sessionRoundTrip(h, defaultSessionCookie+"="+id+".forged"); w.body.String() != "" {
+		t.Fatalf("body with a forged cookie got: %q, want none", w.body.String())
+	}
+	if w := sessionRoundTrip(h, defaultSessionCookie+"="+id); w.body.String() != "" {
+		t.Fatalf("body with an unsigned cookie got: %q, want none", w.body.String())
+	}
+
+	action = "destroy"
+	w = sessionRoundTrip(h, renewed)
+	if got := w.header.Get("Set-Cookie"); !strings.HasPrefix(got, defaultSessionCookie+"=;") || !strings.Contains(got, "Max-Age=0") {
+		t.Fatalf("Set-Cookie got: %q, want the cookie deleted", got)
+	}
+	if _, err := store.Load(id); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("Load error got: %v, want: %v", err, ErrNoSession)
+	}
+}
+
+func TestSessionsCookieBeforeBody(t *testing.T) {
+	// The cookie must be set even though the handler writes the
+	// body, and so sends the headers, after changing the session
+	h := Sessions(HandlerFunc(func(w ResponseWriter, req *Request) {
+		req.Session().Set("a", "1")
+		io.WriteString(w, "body")
+		req.Session().Set("b", "2")
+	}), &SessionConfig{Store: NewMemoryStore(), Key: testSessionKey, CookieName: "sid", Secure: true})
+	s := &Server{Addr: ":0", Handler: h}
+	res := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+	head, body, _ := strings.Cut(res, "\r\n\r\n")
+	if !strings.Contains(head, "\r\nSet-Cookie: sid=") || !strings.Contains(head, "; Secure") {
+		t.Fatalf("headers got: %q, want a secure sid cookie", head)
+	}
+	if body != "body" {
+		t.Fatalf("body got: %q, want: %q", body, "body")
+	}
+}
+
+func TestSessionsShortKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Sessions got no panic, want one for a short key")
+		}
+	}()
+	Sessions(NotFoundHandler(), &SessionConfig{Store: NewMemoryStore(), Key: []byte("short")})
+}
+
+func TestSessionStores(t *testing.T) {
+	var tests = []struct {
+		name  string
+		store SessionStore
+	}{
+		{"Memory", NewMemoryStore()},
+		{"File", NewFileStore(t.TempDir())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := newSessionID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.store.Load(id); !errors.Is(err, ErrNoSession) {
+				t.Fatalf("Load of a missing session error got: %v, want: %v", err, ErrNoSession)
+			}
+			values := map[string]string{"user": "alice"}
+			if err := tt.store.Save(id, values, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			values["user"] = "changed"
+			got, err := tt.store.Load(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["user"] != "alice" {
+				t.Fatalf("user got: %q, want: %q", got["user"], "alice")
+			}
+
+			if err := tt.store.Delete(id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.store.Load(id); !errors.Is(err, ErrNoSession) {
+				t.Fatalf("Load of a deleted session error got: %v, want: %v", err, ErrNoSession)
+			}
+
+			if err := tt.store.Save(id, values, time.Now().Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tt.store.Load(id); !errors.Is(err, ErrNoSession) {
+				t.Fatalf("Load of an expired session error got: %v, want: %v", err, ErrNoSession)
+			}
+
+			if _, err := tt.store.Load("../escape"); !errors.Is(err, ErrNoSession) {
+				t.Fatalf("Load of an invalid ID error got: %v, want: %v", err, ErrNoSession)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// shutdownPollInterval is how often Shutdown checks whether
+// the active connections have become idle.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// ErrServerClosed is returned by Serve and ListenAndServe
+// after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("gohttp: Server closed")
+
+// connState is the state of a connection handled by the server.
+type connState int
+
+const (
+	// stateIdle means the connection waits for the next request.
+	stateIdle connState = iota
+	// stateActive means a request is being read or responded to.
+	stateActive
+)
+
+// Shutdown gracefully shuts down the server. It closes all listeners,
+// then closes connections as soon as they are idle, letting requests
+// in flight finish. It returns once all connections are closed, or
+// with the error of ctx if it is done first, in which case the
+// remaining connections are left open; call Close to close them.
+//
+// Serve and ListenAndServe return ErrServerClosed right away,
+// without waiting for Shutdown to return.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.inShutdown = true
+	err := s.closeListenersLocked()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all listeners and connections,
+// interrupting requests in flight. Use Shutdown to let them finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inShutdown = true
+	err := s.closeListenersLocked()
+	for c := range s.conns {
+		c.Close()
+		s.removeConnLocked(c)
+	}
+	return err
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
+
+func (s *Server) closeListenersLocked() error {
+	var err error
+	for ln := range s.listeners {
+		if cerr := ln.Close(); cerr != nil && !errors.Is(cerr, net.ErrClosed) && err == nil {
+			err = cerr
+		}
+		delete(s.listeners, ln)
+	}
+	return err
+}
+
+// closeIdleConns closes the idle connections, and reports
+// whether no connections are left.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, state := range s.conns {
+		if state == stateIdle {
+			c.Close()
+			s.removeConnLocked(c)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+// trackListener adds ln to or removes it from the listeners closed
+// on shutdown. It reports false when adding after shutdown has begun.
+func (s *Server) trackListener(ln net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, ln)
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[ln] = struct{}{}
+	return true
+}
+
+// trackConn adds c, as idle, to or removes it from the connections
+// closed on shutdown. It reports false when adding after shutdown
+// has begun.
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		s.removeConnLocked(c)
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]connState)
+	}
+	s.conns[c] = stateIdle
+	if s.Metrics != nil {
+		s.Metrics.connOpened()
+	}
+	return true
+}
+
+// removeConnLocked stops tracking c, if it still is.
+func (s *Server) removeConnLocked(c net.Conn) {
+	state, ok := s.conns[c]
+	if !ok {
+		return
+	}
+	delete(s.conns, c)
+	if s.Metrics != nil {
+		s.Metrics.connClosed(state)
+	}
+}
+
+// setConnState records the state of c. It reports false if c
+// must not go on, because the server is shutting down and c is
+// either already closed or about to become idle.
+func (s *Server) setConnState(c net.Conn, state connState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, ok := s.conns[c]
+	if !ok {
+		return false
+	}
+	if s.inShutdown && state == stateIdle {
+		s.removeConnLocked(c)
+		return false
+	}
+	s.conns[c] = state
+	if s.Metrics != nil && from != state {
+		s.Metrics.connStateChanged(from, state)
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package gohttp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves s on a local listener, and returns its address
+// and a channel receiving the error Serve returns.
+func startServer(t *testing.T, s *Server) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(ln) }()
+	t.Cleanup(func() { s.Close() })
+	return ln.Addr().String(), errc
+}
+
+// readResponse reads a response with a "Content-Length" from br,
+// and returns its status line and body.
+func readResponse(t *testing.T, br *bufio.Reader) (string, string) {
+	t.Helper()
+	statusLine, err := ReadLine(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var length int
+	for {
+		line, err := ReadLine(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "" {
+			break
+		}
+		if v := strings.TrimPrefix(line, "Content-Length: "); v != line {
+			if length, err = strconv.Atoi(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		t.Fatal(err)
+	}
+	return statusLine, string(body)
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := NewServeMux()
+	mux.HandleFunc("/fast", func(w ResponseWriter, req *Request) {
+		io.WriteString(w, "fast")
+	})
+	mux.HandleFunc("/slow", func(w ResponseWriter, req *Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "slow")
+	})
+	s := &Server{Addr: ":0", Handler: mux}
+	addr, serveErr := startServer(t, s)
+
+	// An idle keep-alive connection, which is closed right away
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	idleReader := bufio.NewReader(idle)
+	io.WriteString(idle, "GET /fast HTTP/1.1\r\nHost: test\r\n\r\n")
+	if _, body := readResponse(t, idleReader); body != "fast" {
+		t.Fatalf("body got: %q, want: %q", body, "fast")
+	}
+
+	// A connection with a request in flight, which is let finish
+	active, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer active.Close()
+	activeReader := bufio.NewReader(active)
+	io.WriteString(active, "GET /slow HTTP/1.1\r\nHost: test\r\n\r\n")
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- s.Shutdown(context.Background()) }()
+
+	if err := <-serveErr; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve error got: %v, want: %v", err, ErrServerClosed)
+	}
+	idle.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := idleReader.ReadByte(); !errors.Is(err, io.EOF) {
+		t.Fatalf("idle connection read error got: %v, want: %v", err, io.EOF)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatal("dial error got: nil, want the listener closed")
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v before the request finished", err)
+	case <-time.After(2 * shutdownPollInterval):
+	}
+
+	close(release)
+	active.SetReadDeadline(time.Now().Add(10 * time.Second))
+	statusLine, body := readResponse(t, activeReader)
+	if statusLine != "HTTP/1.1 200 OK" || body != "slow" {
+		t.Fatalf("response got: %q %q, want: %q %q", statusLine, body, "HTTP/1.1 200 OK", "slow")
+	}
+	if _, err := activeReader.ReadByte(); !errors.Is(err, io.EOF) {
+		t.Fatalf("active connection read error got: %v, want: %v", err, io.EOF)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown error got: %v, want: nil", err)
+	}
+}
+
+func TestShutdownContextDone(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s := &Server{Addr: ":0", Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+		close(started)
+		<-release
+	})}
+	addr, _ := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown error got: %v, want: %v", err, context.DeadlineExceeded)
+	}
+
+	// Close interrupts the request still in flight
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("read error got: %v, want: %v", err, io.EOF)
+	}
+}
+
+func TestServeAfterClose(t *testing.T) {
+	s := &Server{Addr: ":0", DocRoot: "testdata"}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(ln); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve error got: %v, want: %v", err, ErrServerClosed)
+	}
+}
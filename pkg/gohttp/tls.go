@@ -0,0 +1,172 @@
+package gohttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often a CertStore checks whether
+// its certificate files have changed on disk.
+const certCheckInterval = time.Second
+
+// A CertStore holds TLS certificates loaded from files. It picks the
+// certificate to present for each connection by the server name the
+// client asks for (SNI), and reloads certificates whose files have
+// changed, so renewed certificates are used without a restart.
+//
+// Use its GetCertificate method as the tls.Config field of the same name.
+type CertStore struct {
+	// Logger receives errors reloading certificates.
+	// If nil, they are not logged.
+	Logger *slog.Logger
+
+	mu        sync.RWMutex
+	certs     []*storedCert
+	lastCheck time.Time
+}
+
+type storedCert struct {
+	certFile, keyFile string
+	modTime           time.Time // latest modification time of both files
+	cert              *tls.Certificate
+}
+
+// NewCertStore returns an empty CertStore.
+func NewCertStore() *CertStore {
+	return &CertStore{}
+}
+
+// Add loads a certificate and its private key from PEM-encoded files.
+// The first certificate added is the default, for clients that don't
+// send a server name matching any certificate.
+func (cs *CertStore) Add(certFile, keyFile string) error {
+	sc := &storedCert{certFile: certFile, keyFile: keyFile}
+	if err := sc.load(); err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.certs = append(cs.certs, sc)
+	return nil
+}
+
+// Reload reloads the certificates whose files have changed since they
+// were last loaded. A certificate that fails to reload is kept as is.
+func (cs *CertStore) Reload() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.lastCheck = time.Now()
+	var errs []error
+	for _, sc := range cs.certs {
+		modTime, err := sc.filesModTime()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if modTime.Equal(sc.modTime) {
+			continue
+		}
+		if err := sc.load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reload certificates: %v", errs)
+	}
+	return nil
+}
+
+// GetCertificate returns the certificate to present to the client
+// sending hello. It is meant to be used as tls.Config.GetCertificate.
+func (cs *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	stale := time.Since(cs.lastCheck) >= certCheckInterval
+	cs.mu.RUnlock()
+	if stale {
+		if err := cs.Reload(); err != nil {
+			cs.logger().Error("failed to reload certificates", "err", err)
+		}
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if len(cs.certs) == 0 {
+		return nil, errors.New("gohttp: no certificates configured")
+	}
+	if hello.ServerName != "" {
+		for _, sc := range cs.certs {
+			if hello.SupportsCertificate(sc.cert) == nil {
+				return sc.cert, nil
+			}
+		}
+	}
+	return cs.certs[0].cert, nil
+}
+
+func (cs *CertStore) logger() *slog.Logger {
+	if cs.Logger != nil {
+		return cs.Logger
+	}
+	return discardLogger
+}
+
+func (sc *storedCert) load() error {
+	modTime, err := sc.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(sc.certFile, sc.keyFile)
+	if err != nil {
+		return err
+	}
+	sc.cert = &cert
+	sc.modTime = modTime
+	return nil
+}
+
+func (sc *storedCert) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, path := range []string{sc.certFile, sc.keyFile} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return modTime, err
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// tlsConfig returns the TLS configuration to serve with, based on
+// s.TLSConfig and the certificate and key files, if not "".
+func (s *Server) tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	var config *tls.Config
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	// Only HTTP/1.1 is spoken here, so that's what ALPN should agree on
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cs := NewCertStore()
+		cs.Logger = s.Logger
+		if err := cs.Add(certFile, keyFile); err != nil {
+			return nil, err
+		}
+		config.GetCertificate = cs.GetCertificate
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		return nil, errors.New("no TLS certificate configured")
+	}
+	return config, nil
+}
@@ -0,0 +1,196 @@
+package gohttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for host and its key
+// to PEM files in dir, and returns their paths and the certificate.
+func writeTestCert(t *testing.T, dir, host string, serial int64) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, host+".crt")
+	keyFile := filepath.Join(dir, host+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+// serveTLS starts s on a local TLS listener configured by config,
+// and returns its address.
+func serveTLS(t *testing.T, s *Server, config *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.Serve(tls.NewListener(ln, config))
+	return ln.Addr().String()
+}
+
+func dialTLS(t *testing.T, addr, serverName string, roots ...*x509.Certificate) *tls.Conn {
+	t.Helper()
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AddCert(root)
+	}
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		NextProtos: []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certA, keyA, rootA := writeTestCert(t, dir, "a.test", 1)
+	certB, keyB, rootB := writeTestCert(t, dir, "b.test", 2)
+
+	cs := NewCertStore()
+	if err := cs.Add(certA, keyA); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Add(certB, keyB); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Addr:      ":0",
+		DocRoot:   "testdata",
+		TLSConfig: &tls.Config{GetCertificate: cs.GetCertificate},
+	}
+	config, err := s.tlsConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTLS(t, s, config)
+
+	var tests = []struct {
+		name       string
+		serverName string
+		certWant   *x509.Certificate
+	}{
+		{"DefaultHost", "a.test", rootA},
+		{"OtherHost", "b.test", rootB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialTLS(t, addr, tt.serverName, rootA, rootB)
+			state := conn.ConnectionState()
+			if got := state.PeerCertificates[0].SerialNumber; got.Cmp(tt.certWant.SerialNumber) != 0 {
+				t.Fatalf("certificate serial number got: %v, want: %v", got, tt.certWant.SerialNumber)
+			}
+			if state.NegotiatedProtocol != "http/1.1" {
+				t.Fatalf("negotiated protocol got: %q, want: %q", state.NegotiatedProtocol, "http/1.1")
+			}
+
+			reqText := "GET /index.html HTTP/1.1\r\nHost: " + tt.serverName + "\r\nConnection: close\r\n\r\n"
+			if _, err := io.WriteString(conn, reqText); err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			resBytes, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(resBytes), "HTTP/1.1 200 OK\r\n") {
+				t.Fatalf("response got: %q, want status 200", resBytes)
+			}
+		})
+	}
+}
+
+func TestCertStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCert(t, dir, "a.test", 1)
+	cs := NewCertStore()
+	if err := cs.Add(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renew the certificate, making sure its files look newer
+	_, _, renewed := writeTestCert(t, dir, "a.test", 2)
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := cs.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !leaf.Equal(renewed) {
+		t.Fatalf("certificate got serial number %v, want the renewed %v", leaf.SerialNumber, renewed.SerialNumber)
+	}
+}
+
+func TestTLSConfigWithoutCertificate(t *testing.T) {
+	s := &Server{Addr: ":0", DocRoot: "testdata"}
+	if _, err := s.tlsConfig("", ""); err == nil {
+		t.Fatal("error got: nil, want an error without a certificate")
+	}
+}
@@ -0,0 +1,234 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// uploadMethods are the methods an UploadHandler supports.
+var uploadMethods = allowedMethods("POST")
+
+// maxUploadNameTries is how many names an UploadHandler tries for a
+// file, e.g. "a.txt", "a-1.txt", "a-2.txt", before giving up.
+const maxUploadNameTries = 100
+
+// UploadHandler is a handler saving the files of multipart/form-data
+// POST requests, such as those sent by an HTML form with an
+// <input type="file"> field, into a directory. A file never replaces
+// another one; it gets a name with a number added instead.
+type UploadHandler struct {
+	// Dir is the directory to save files into,
+	// e.g. a subdirectory of the doc root.
+	Dir string
+
+	// Field is the name of the form field whose files are saved.
+	// If empty, the files of all fields are.
+	Field string
+
+	// MaxUploadBytes limits the size of the request body. Larger
+	// uploads get 413 Content Too Large. If zero,
+	// defaultMaxUploadBytes is used.
+	MaxUploadBytes int64
+
+	// MaxMemory is how many bytes of files are kept in memory while
+	// the form is read; the rest are stored in temporary files.
+	// If zero, defaultMaxMemory is used.
+	MaxMemory int64
+
+	// Redirect is where to send the client with 303 See Other once the
+	// files are saved, e.g. back to the page with the form. If empty,
+	// the response is 201 Created with the names of the saved files,
+	// one per line, as its body.
+	Redirect string
+}
+
+func (h *UploadHandler) ServeGoHTTP(w ResponseWriter, req *Request) {
+	if !checkMethod(w, req, uploadMethods) {
+		return
+	}
+	res := h.prepare(req)
+	if err := res.Send(w); err != nil {
+		req.logger().Warn("failed to send upload response", "err", err)
+	}
+}
+
+func (h *UploadHandler) methods() []string {
+	return uploadMethods
+}
+
+// prepare saves the files of req and generates the corresponding res.
+func (h *UploadHandler) prepare(req *Request) *Response {
+	res := &Response{
+		Header:  make(Header),
+		Request: req,
+	}
+	limit := h.MaxUploadBytes
+	if limit <= 0 {
+		limit = defaultMaxUploadBytes
+	}
+	if req.ContentLength > limit {
+		res.HandleError(statusContentTooLarge)
+		return res
+	}
+	maxMemory := h.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	body := &limitedBody{ReadCloser: req.Body, n: limit}
+	req.Body = body
+	err := req.ParseMultipartForm(maxMemory)
+	switch {
+	case body.exceeded:
+		res.HandleError(statusContentTooLarge)
+		return res
+	case errors.Is(err, ErrNotMultipart):
+		res.HandleError(statusUnsupportedMediaType)
+		return res
+	case err != nil:
+		req.logger().Debug("failed to parse upload form", "err", err)
+		res.HandleError(errorStatus(err))
+		return res
+	}
+
+	files := h.files(req.MultipartForm)
+	if len(files) == 0 {
+		req.logger().Debug("no file uploaded", "field", h.Field)
+		res.HandleError(statusBadRequest)
+		return res
+	}
+	for _, fh := range files {
+		if !validUploadName(fh.Filename) {
+			req.logger().Debug("invalid upload file name", "name", fh.Filename)
+			res.HandleError(statusBadRequest)
+			return res
+		}
+	}
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		req.logger().Warn("failed to create upload directory", "dir", h.Dir, "err", err)
+		res.HandleError(statusInternalServerError)
+		return res
+	}
+	var saved strings.Builder
+	for _, fh := range files {
+		name, err := h.save(fh)
+		if err != nil {
+			req.logger().Warn("failed to save uploaded file", "name", fh.Filename, "err", err)
+			res.HandleError(statusInternalServerError)
+			return res
+		}
+		req.logger().Debug("saved uploaded file", "name", name, "size", fh.Size)
+		saved.WriteString(name + "\n")
+	}
+
+	if h.Redirect != "" {
+		res.HandleRedirect(req, h.Redirect, statusSeeOther)
+		return res
+	}
+	res.handleStatus(req, statusCreated)
+	res.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	res.Body = strings.NewReader(saved.String())
+	return res
+}
+
+// files returns the file parts of form to save, in the order of
+// their field names.
+func (h *UploadHandler) files(form *multipart.Form) []*multipart.FileHeader {
+	if h.Field != "" {
+		return form.File[h.Field]
+	}
+	fields := make([]string, 0, len(form.File))
+	for field := range form.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var files []*multipart.FileHeader
+	for _, field := range fields {
+		files = append(files, form.File[field]...)
+	}
+	return files
+}
+
+// validUploadName reports whether name, the file name sent with an
+// uploaded file, can be used to save it. It must be a plain file name,
+// not hidden, without any directory.
+func validUploadName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsAny(name, "/\\\x00")
+}
+
+// save writes the uploaded file fh into h.Dir, and returns the name
+// it is saved with. The file is written to a temporary file first,
+// which is then linked to a free name, so that no file is replaced
+// and readers never see a partial file.
+func (h *UploadHandler) save(fh *multipart.FileHeader) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(h.Dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(fh.Filename)
+	stem := strings.TrimSuffix(fh.Filename, ext)
+	for i := 0; i < maxUploadNameTries; i++ {
+		name := fh.Filename
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+		err := os.Link(tmp.Name(), filepath.Join(h.Dir, name))
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free name for %v", fh.Filename)
+}
+
+// limitedBody is a request body of at most n bytes.
+// Reading past that fails, and sets exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		// Only an attempt to read more than the limit is an error
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			b.exceeded = true
+			return 0, &statusError{statusContentTooLarge, "request body too large"}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,166 @@
+package gohttp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadHandler(t *testing.T) {
+	var tests = []struct {
+		name        string
+		handler     UploadHandler
+		field       string
+		files       map[string]string
+		contentType string // overrides the multipart Content-Type if set
+		chunked     bool
+		statusWant  int
+		savedWant   map[string]string // the files in the directory afterwards
+	}{
+		{
+			"Save",
+			UploadHandler{},
+			"file",
+			map[string]string{"a.txt": "a"},
+			"",
+			false,
+			201,
+			map[string]string{"a.txt": "a", "old.txt": "old"},
+		},
+		{
+			"NameTaken",
+			UploadHandler{},
+			"file",
+			map[string]string{"old.txt": "new"},
+			"",
+			false,
+			201,
+			map[string]string{"old.txt": "old", "old-1.txt": "new"},
+		},
+		{
+			"OtherField",
+			UploadHandler{Field: "doc"},
+			"file",
+			map[string]string{"a.txt": "a"},
+			"",
+			false,
+			400,
+			map[string]string{"old.txt": "old"},
+		},
+		{
+			"HiddenName",
+			UploadHandler{},
+			"file",
+			map[string]string{".htaccess": "deny"},
+			"",
+			false,
+			400,
+			map[string]string{"old.txt": "old"},
+		},
+		{
+			"PathInName",
+			UploadHandler{},
+			"file",
+			map[string]string{"../escape.txt": "a"},
+			"",
+			false,
+			201,
+			map[string]string{"escape.txt": "a", "old.txt": "old"},
+		},
+		{
+			"TooLarge",
+			UploadHandler{MaxUploadBytes: 100},
+			"file",
+			map[string]string{"a.txt": strings.Repeat("a", 200)},
+			"",
+			false,
+			413,
+			map[string]string{"old.txt": "old"},
+		},
+		{
+			"TooLargeChunked",
+			UploadHandler{MaxUploadBytes: 100},
+			"file",
+			map[string]string{"a.txt": strings.Repeat("a", 200)},
+			"",
+			true,
+			413,
+			map[string]string{"old.txt": "old"},
+		},
+		{
+			"NotMultipart",
+			UploadHandler{},
+			"file",
+			map[string]string{"a.txt": "a"},
+			"text/plain",
+			false,
+			415,
+			map[string]string{"old.txt": "old"},
+		},
+		{
+			"Redirect",
+			UploadHandler{Redirect: "/drop.html"},
+			"file",
+			map[string]string{"a.txt": "a"},
+			"",
+			false,
+			303,
+			map[string]string{"a.txt": "a", "old.txt": "old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			makeTree(t, dir, map[string]string{"old.txt": "old"})
+			h := tt.handler
+			h.Dir = dir
+
+			body, contentType := multipartBody(t, map[string]string{"note": "hi"}, tt.field, tt.files)
+			if tt.contentType != "" {
+				contentType = tt.contentType
+			}
+			req := writeRequest("POST", "/upload", body, tt.chunked)
+			req.Header.Set("Content-Type", contentType)
+			w := newRecorder()
+			h.ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			if tt.statusWant == 303 && w.header.Get("Location") != h.Redirect {
+				t.Fatalf("Location got: %q, want: %q", w.header.Get("Location"), h.Redirect)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := make(map[string]string)
+			for _, e := range entries {
+				content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				saved[e.Name()] = string(content)
+			}
+			if len(saved) != len(tt.savedWant) {
+				t.Fatalf("files got: %v, want: %v", saved, tt.savedWant)
+			}
+			for name, content := range tt.savedWant {
+				if saved[name] != content {
+					t.Fatalf("files got: %v, want: %v", saved, tt.savedWant)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadHandlerMethods(t *testing.T) {
+	h := &UploadHandler{Dir: t.TempDir()}
+	w := newRecorder()
+	h.ServeGoHTTP(w, writeRequest("GET", "/upload", "", false))
+	if w.status != 405 || w.header.Get("Allow") != "POST, OPTIONS" {
+		t.Fatalf("response got: %v %q, want: %v %q", w.status, w.header.Get("Allow"), 405, "POST, OPTIONS")
+	}
+}
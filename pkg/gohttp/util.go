@@ -2,6 +2,7 @@ package gohttp
 
 import (
 	"bufio"
+	"bytes"
 	"mime"
 	"net/textproto"
 	"strings"
@@ -29,6 +30,19 @@ func FormatTime(t time.Time) string {
 	return s
 }
 
+// ParseTime parses a time header value, such as "If-Modified-Since",
+// in any of the three formats allowed by the HTTP spec.
+func ParseTime(s string) (time.Time, error) {
+	var t time.Time
+	var err error
+	for _, layout := range []string{time.RFC1123, time.RFC850, time.ANSIC} {
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
 // MIMETypeByExtension returns the MIME type associated with the
 // file extension ext. The extension ext should begin with a
 // leading dot, as in ".html". When ext has no associated type,
@@ -59,3 +73,27 @@ func ReadLine(br *bufio.Reader) (string, error) {
 		}
 	}
 }
+
+// readLineLimit is like ReadLine, but fails with errLineTooLong
+// without reading further once the line, including its line end,
+// is longer than max bytes.
+func readLineLimit(br *bufio.Reader, max int) (string, error) {
+	var line []byte
+	for {
+		frag, err := br.ReadSlice('\n')
+		if len(line)+len(frag) > max {
+			return "", errLineTooLong
+		}
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return string(line), err
+		}
+		// Return the line when reaching line end
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			return string(line[:len(line)-2]), nil
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package gohttp
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// Hosts returns a handler that dispatches each request to the handler
+// of hosts registered for its "Host" header, so that one server can
+// serve several sites. Requests for unknown hosts go to fallback.
+//
+// Host names are matched ignoring case and the port. A name of the
+// form "*.example.com" matches any subdomain of example.com, but not
+// example.com itself. Exact names take precedence over wildcards, and
+// longer wildcards take precedence over shorter ones.
+func Hosts(hosts map[string]Handler, fallback Handler) Handler {
+	hh := &hostHandler{
+		exact:    make(map[string]Handler),
+		fallback: fallback,
+	}
+	for name, h := range hosts {
+		name = normalizeHost(name)
+		if strings.HasPrefix(name, "*.") {
+			hh.wildcards = append(hh.wildcards, muxEntry{pattern: name[1:], handler: h})
+		} else {
+			hh.exact[name] = h
+		}
+	}
+	sort.SliceStable(hh.wildcards, func(i, j int) bool {
+		return len(hh.wildcards[i].pattern) > len(hh.wildcards[j].pattern)
+	})
+	return hh
+}
+
+type hostHandler struct {
+	exact     map[string]Handler
+	wildcards []muxEntry // patterns like ".example.com", longest first
+	fallback  Handler
+}
+
+func (hh *hostHandler) ServeGoHTTP(w ResponseWriter, req *Request) {
+	hh.handler(req.Host).ServeGoHTTP(w, req)
+}
+
+func (hh *hostHandler) methods() []string {
+	handlers := []Handler{hh.fallback}
+	for _, h := range hh.exact {
+		handlers = append(handlers, h)
+	}
+	for _, e := range hh.wildcards {
+		handlers = append(handlers, e.handler)
+	}
+	return handlerMethods(handlers...)
+}
+
+// handler returns the handler for requests to host.
+func (hh *hostHandler) handler(host string) Handler {
+	name := normalizeHost(host)
+	if h, ok := hh.exact[name]; ok {
+		return h
+	}
+	for _, e := range hh.wildcards {
+		if strings.HasSuffix(name, e.pattern) && len(name) > len(e.pattern) {
+			return e.handler
+		}
+	}
+	return hh.fallback
+}
+
+// normalizeHost strips the port, if any, and the trailing dot of
+// a fully qualified name from host, and converts it to lower case.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(host)
+}
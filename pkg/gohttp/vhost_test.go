@@ -0,0 +1,82 @@
+package gohttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHosts(t *testing.T) {
+	named := func(name string) Handler {
+		return HandlerFunc(func(w ResponseWriter, req *Request) {
+			w.Header().Set("Handler", name)
+		})
+	}
+	h := Hosts(map[string]Handler{
+		"example.com":          named("example"),
+		"*.example.com":        named("wildcard"),
+		"*.static.example.com": named("static"),
+		"Shop.Example.com":     named("shop"),
+	}, named("default"))
+
+	var tests = []struct {
+		name        string
+		host        string
+		handlerWant string
+	}{
+		{"Exact", "example.com", "example"},
+		{"Port", "example.com:8080", "example"},
+		{"Case", "EXAMPLE.com", "example"},
+		{"TrailingDot", "example.com.", "example"},
+		{"ExactOverWildcard", "shop.example.com", "shop"},
+		{"Wildcard", "blog.example.com", "wildcard"},
+		{"WildcardNested", "a.b.example.com", "wildcard"},
+		{"LongestWildcard", "cdn.static.example.com:443", "static"},
+		{"WildcardNotApex", "static.example.com", "wildcard"},
+		{"WildcardNotSuffix", "notexample.com", "default"},
+		{"Unknown", "other.org", "default"},
+		{"IPv6", "[::1]:8080", "default"},
+		{"Empty", "", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{}, Host: tt.host}
+			w := newRecorder()
+			h.ServeGoHTTP(w, req)
+			if got := w.header.Get("Handler"); got != tt.handlerWant {
+				t.Fatalf("handler got: %q, want: %q", got, tt.handlerWant)
+			}
+		})
+	}
+}
+
+func TestServerVirtualHosts(t *testing.T) {
+	s := &Server{
+		Addr: ":0",
+		VirtualHosts: map[string]Handler{
+			"site.test": FileServer("testdata"),
+		},
+	}
+	if err := s.ValidateServerSetup(); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name           string
+		reqText        string
+		statusLineWant string
+	}{
+		{"KnownHost", "GET /index.html HTTP/1.1\r\nHost: site.test:8080\r\nConnection: close\r\n\r\n", "HTTP/1.1 200 OK\r\n"},
+		{"UnknownHost", "GET /index.html HTTP/1.1\r\nHost: other.test\r\nConnection: close\r\n\r\n", "HTTP/1.1 404 Not Found\r\n"},
+		{"MissingHost", "GET /index.html HTTP/1.1\r\nConnection: close\r\n\r\n", "HTTP/1.1 400 Bad Request\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resGot := roundTrip(t, s, tt.reqText)
+			if !strings.HasPrefix(resGot, tt.statusLineWant) {
+				t.Fatalf("response got: %q, want status line: %q", resGot, tt.statusLineWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package gohttp
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultMaxUploadBytes is the default limit on the size of
+// the body of a PUT request.
+const defaultMaxUploadBytes = 32 << 20
+
+func (f *FileHandler) maxUploadBytes() int64 {
+	if f.MaxUploadBytes > 0 {
+		return f.MaxUploadBytes
+	}
+	return defaultMaxUploadBytes
+}
+
+// writableDir returns the element of f.Writable that name, a path from
+// cleanPath, is in, or "" if name is read-only.
+func (f *FileHandler) writableDir(name string) string {
+	for _, dir := range f.Writable {
+		dir = path.Clean("/" + dir)
+		if name == dir || strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+			return dir
+		}
+	}
+	return ""
+}
+
+// writeTarget returns the path of the file that PUT or DELETE change
+// for name, a path from cleanPath. Its parent directory is resolved
+// as for reads, and must still be in the Writable directory of name.
+// The file itself is not followed, so that a symbolic link is
+// replaced or removed rather than its target.
+func (f *FileHandler) writeTarget(root, name string) (string, error) {
+	dir := f.writableDir(name)
+	if dir == "" {
+		return "", errForbiddenPath
+	}
+	dirPath, err := f.resolve(root, dir)
+	if err != nil {
+		return "", err
+	}
+	parent, err := f.resolve(root, path.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	base := path.Base(name)
+	if base == "/" || f.hidden(base) || !inRoot(dirPath, parent) {
+		return "", errForbiddenPath
+	}
+	return filepath.Join(parent, base), nil
+}
+
+// prepareWrite sets up res, the response to a PUT or DELETE request
+// with f.Root opened as r, and returns the name relative to r of the
+// file req changes. If req can't change a file, it returns "" with
+// res ready to send.
+//
+// The file and its directory are then changed through r, so that
+// a symbolic link swapped in since they were resolved can't lead
+// outside of it.
+func (f *FileHandler) prepareWrite(req *Request, res *Response, r *os.Root) string {
+	res.Proto = responseProto
+	name, err := cleanPath(req.URL.Path)
+	if err != nil {
+		res.HandleError(statusBadRequest)
+		return ""
+	}
+	target, err := f.writeTarget(r.Name(), name)
+	if err == nil {
+		target, err = rootName(r, target)
+	}
+	if err != nil {
+		req.logger().Debug("file not writable", "path", name, "err", err)
+		res.HandleNotFound(req)
+		return ""
+	}
+	return target
+}
+
+// checkWrite evaluates the preconditions of req, a PUT or DELETE
+// request, against the current file named target in r, and reports
+// whether target exists. If a precondition fails, or target is
+// a directory, it turns res into the response to send and done is true.
+func (f *FileHandler) checkWrite(req *Request, res *Response, r *os.Root, target string) (exists, done bool) {
+	fi, err := r.Stat(target)
+	if err != nil {
+		// "If-Match" can't match a missing file (RFC 9110, Section 13.1.1)
+		if req.Header.has("If-Match") {
+			res.handleStatus(req, statusPreconditionFailed)
+			return false, true
+		}
+		return false, false
+	}
+	if fi.IsDir() {
+		res.handleStatus(req, statusConflict)
+		return true, true
+	}
+	res.Header.Set("Etag", fileETag(fi, f.WeakETags))
+	res.Header.Set("Last-Modified", FormatTime(fi.ModTime()))
+	done = res.handleConditional(req)
+	res.Header.Del("Etag")
+	res.Header.Del("Last-Modified")
+	if done {
+		res.handleStatus(req, statusPreconditionFailed)
+	}
+	return true, done
+}
+
+// put creates or replaces the file named by req with the request body.
+// The body is written to a temporary file next to it first, which is
+// then renamed over the file, so that readers never see a partial file.
+func (f *FileHandler) put(req *Request, r *os.Root) *Response {
+	res := &Response{
+		Header:  make(Header),
+		Request: req,
+	}
+	target := f.prepareWrite(req, res, r)
+	if target == "" {
+		return res
+	}
+	limit := f.maxUploadBytes()
+	if req.ContentLength > limit {
+		res.HandleError(statusContentTooLarge)
+		return res
+	}
+	// Check the preconditions before the client sends the body,
+	// and again before replacing the file
+	if _, done := f.checkWrite(req, res, r, target); done {
+		return res
+	}
+
+	dir := filepath.Dir(target)
+	if err := r.MkdirAll(dir, 0o755); err != nil {
+		req.logger().Debug("failed to create directory", "path", dir, "err", err)
+		res.handleStatus(req, statusConflict)
+		return res
+	}
+	tmpName, statusCode := upload(req, r, dir, limit)
+	if statusCode != statusOK {
+		res.HandleError(statusCode)
+		return res
+	}
+	defer r.Remove(tmpName)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exists, done := f.checkWrite(req, res, r, target)
+	if done {
+		return res
+	}
+	if err := r.Rename(tmpName, target); err != nil {
+		req.logger().Warn("failed to replace file", "path", target, "err", err)
+		res.HandleError(statusInternalServerError)
+		return res
+	}
+	if fi, err := r.Stat(target); err == nil {
+		res.Header.Set("Etag", fileETag(fi, f.WeakETags))
+	}
+	if exists {
+		res.handleStatus(req, statusNoContent)
+	} else {
+		res.handleStatus(req, statusCreated)
+		res.Header.Set("Location", req.URL.EscapedPath())
+	}
+	return res
+}
+
+// upload writes the body of req, at most limit bytes long, to a new
+// temporary file in dir, a directory in r. It returns the name of the
+// file in r, or "" and the status code to respond with if the upload
+// fails.
+func upload(req *Request, r *os.Root, dir string, limit int64) (string, int) {
+	// The temporary file is a dotfile, hidden while it is written
+	name := filepath.Join(dir, ".upload-"+rand.Text())
+	tmp, err := r.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		req.logger().Warn("failed to create temporary file", "dir", dir, "err", err)
+		return "", statusInternalServerError
+	}
+	n, err := io.Copy(tmp, io.LimitReader(req.Body, limit+1))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = r.Chmod(name, 0o644)
+	}
+	statusCode := statusOK
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &pathErr):
+		req.logger().Warn("failed to write temporary file", "path", name, "err", err)
+		statusCode = statusInternalServerError
+	case err != nil:
+		req.logger().Debug("failed to read request body", "err", err)
+		statusCode = errorStatus(err)
+	case n > limit:
+		statusCode = statusContentTooLarge
+	}
+	if statusCode != statusOK {
+		r.Remove(name)
+		return "", statusCode
+	}
+	return name, statusOK
+}
+
+// delete removes the file named by req.
+func (f *FileHandler) delete(req *Request, r *os.Root) *Response {
+	res := &Response{
+		Header:  make(Header),
+		Request: req,
+	}
+	target := f.prepareWrite(req, res, r)
+	if target == "" {
+		return res
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exists, done := f.checkWrite(req, res, r, target)
+	if done {
+		return res
+	}
+	if !exists {
+		res.HandleNotFound(req)
+		return res
+	}
+	if err := r.Remove(target); err != nil {
+		req.logger().Warn("failed to remove file", "path", target, "err", err)
+		res.HandleError(statusInternalServerError)
+		return res
+	}
+	res.handleStatus(req, statusNoContent)
+	return res
+}
+
+// handleStatus prepares res to be a response with statusCode
+// and no body, ready to be written back to client.
+func (res *Response) handleStatus(req *Request, statusCode int) {
+	res.Header.Set("Date", FormatTime(time.Now()))
+	res.Proto = responseProto
+	res.StatusCode = statusCode
+	res.FilePath = ""
+	if req.Close {
+		res.Header.Set("Connection", "close")
+	}
+}
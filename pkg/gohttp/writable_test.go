@@ -0,0 +1,233 @@
+package gohttp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRequest returns a request to change the file at target with
+// method, with body as its body, of unknown length if chunked is set.
+func writeRequest(method, target, body string, chunked bool) *Request {
+	req := &Request{
+		Method:        method,
+		URL:           mustParseURL(target),
+		Proto:         "HTTP/1.1",
+		Header:        Header{},
+		Body:          NoBody,
+		ContentLength: int64(len(body)),
+	}
+	if body != "" {
+		req.Body = io.NopCloser(strings.NewReader(body))
+	}
+	if chunked {
+		req.ContentLength = -1
+	}
+	return req
+}
+
+func TestFileHandlerPut(t *testing.T) {
+	var tests = []struct {
+		name       string
+		url        string
+		header     map[string]string
+		body       string
+		chunked    bool
+		statusWant int
+		fileWant   string // the content of the file afterwards, "" if missing
+	}{
+		{"Create", "/up/new.txt", nil, "new", false, 201, "new"},
+		{"CreateNested", "/up/a/b/c.txt", nil, "c", false, 201, "c"},
+		{"Replace", "/up/old.txt", nil, "new", false, 204, "new"},
+		{"Chunked", "/up/old.txt", nil, "new", true, 204, "new"},
+		{"IfMatch", "/up/old.txt", map[string]string{"If-Match": "current"}, "new", false, 204, "new"},
+		{"IfMatchStale", "/up/old.txt", map[string]string{"If-Match": `"stale"`}, "new", false, 412, "old"},
+		{"IfMatchMissing", "/up/new.txt", map[string]string{"If-Match": "*"}, "new", false, 412, ""},
+		{"IfNoneMatchExisting", "/up/old.txt", map[string]string{"If-None-Match": "*"}, "new", false, 412, "old"},
+		{"IfNoneMatchMissing", "/up/new.txt", map[string]string{"If-None-Match": "*"}, "new", false, 201, "new"},
+		{"TooLarge", "/up/new.txt", nil, "0123456789abcdef", false, 413, ""},
+		{"TooLargeChunked", "/up/old.txt", nil, "0123456789abcdef", true, 413, "old"},
+		{"ReadOnly", "/docs/a.txt", nil, "new", false, 405, "a"},
+		{"Hidden", "/up/.env", nil, "new", false, 404, ""},
+		{"Directory", "/up/sub", nil, "new", false, 409, ""},
+		{"ParentIsFile", "/up/old.txt/new.txt", nil, "new", false, 409, ""},
+		{"SymlinkOutOfWritable", "/up/docs/new.txt", nil, "new", false, 404, ""},
+		{"ReplaceSymlink", "/up/link.txt", nil, "new", false, 204, "new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, map[string]string{
+				"docs/a.txt": "a",
+				"up/old.txt": "old",
+				"up/sub/":    "",
+			})
+			if err := os.Symlink("../docs", filepath.Join(root, "up/docs")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("../docs/a.txt", filepath.Join(root, "up/link.txt")); err != nil {
+				t.Fatal(err)
+			}
+			f := &FileHandler{Root: root, Writable: []string{"/up"}, MaxUploadBytes: 8}
+
+			req := writeRequest("PUT", tt.url, tt.body, tt.chunked)
+			for k, v := range tt.header {
+				if v == "current" {
+					fi, err := os.Stat(filepath.Join(root, "up/old.txt"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					v = fileETag(fi, false)
+				}
+				req.Header.Set(k, v)
+			}
+			w := newRecorder()
+			f.ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			if tt.statusWant == 201 && w.header.Get("Location") != tt.url {
+				t.Fatalf("Location got: %q, want: %q", w.header.Get("Location"), tt.url)
+			}
+
+			content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(tt.url)))
+			if err != nil && !os.IsNotExist(err) && tt.fileWant != "" {
+				t.Fatal(err)
+			}
+			if string(content) != tt.fileWant {
+				t.Fatalf("file content got: %q, want: %q", content, tt.fileWant)
+			}
+			if tt.name == "ReplaceSymlink" {
+				if a, _ := os.ReadFile(filepath.Join(root, "docs/a.txt")); string(a) != "a" {
+					t.Fatalf("link target content got: %q, want: %q", a, "a")
+				}
+			}
+			leftovers, err := filepath.Glob(filepath.Join(root, "up", ".upload-*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leftovers) != 0 {
+				t.Fatalf("temporary files left: %v", leftovers)
+			}
+		})
+	}
+}
+
+func TestFileHandlerDelete(t *testing.T) {
+	var tests = []struct {
+		name       string
+		url        string
+		ifMatch    string
+		statusWant int
+		path       string // the file to check afterwards
+		existsWant bool
+	}{
+		{"File", "/up/old.txt", "", 204, "up/old.txt", false},
+		{"IfMatch", "/up/old.txt", "current", 204, "up/old.txt", false},
+		{"IfMatchStale", "/up/old.txt", `"stale"`, 412, "up/old.txt", true},
+		{"Missing", "/up/missing.txt", "", 404, "up/missing.txt", false},
+		{"Directory", "/up/sub", "", 409, "up/sub", true},
+		{"ReadOnly", "/docs/a.txt", "", 405, "docs/a.txt", true},
+		{"Symlink", "/up/link.txt", "", 204, "docs/a.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, map[string]string{
+				"docs/a.txt": "a",
+				"up/old.txt": "old",
+				"up/sub/":    "",
+			})
+			if err := os.Symlink("../docs/a.txt", filepath.Join(root, "up/link.txt")); err != nil {
+				t.Fatal(err)
+			}
+			f := &FileHandler{Root: root, Writable: []string{"/up/"}}
+
+			req := writeRequest("DELETE", tt.url, "", false)
+			if tt.ifMatch == "current" {
+				fi, err := os.Stat(filepath.Join(root, "up/old.txt"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("If-Match", fileETag(fi, false))
+			} else if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := newRecorder()
+			f.ServeGoHTTP(w, req)
+			if w.status != tt.statusWant {
+				t.Fatalf("status code got: %v, want: %v", w.status, tt.statusWant)
+			}
+			_, err := os.Lstat(filepath.Join(root, filepath.FromSlash(tt.path)))
+			if exists := err == nil; exists != tt.existsWant {
+				t.Fatalf("%v exists got: %v, want: %v", tt.path, exists, tt.existsWant)
+			}
+		})
+	}
+}
+
+func TestFileHandlerWritableMethods(t *testing.T) {
+	var tests = []struct {
+		name      string
+		url       string
+		allowWant string
+	}{
+		{"ReadOnly", "/docs/a.txt", "GET, HEAD, OPTIONS"},
+		{"Writable", "/up/a.txt", "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{"SimilarPrefix", "/upper/a.txt", "GET, HEAD, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &FileHandler{Root: t.TempDir(), Writable: []string{"/up"}}
+			w := newRecorder()
+			f.ServeGoHTTP(w, writeRequest("OPTIONS", tt.url, "", false))
+			if got := w.header.Get("Allow"); got != tt.allowWant {
+				t.Fatalf("Allow got: %q, want: %q", got, tt.allowWant)
+			}
+		})
+	}
+}
+
+func TestFileHandlerWriteSymlinkSwapped(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "www")
+	makeTree(t, dir, map[string]string{
+		"www/up/a.txt":     "a",
+		"outside/up/a.txt": "outside",
+	})
+	f := &FileHandler{Root: root, Writable: []string{"/up"}}
+	r, err := f.acquireRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.releaseRoot(r)
+	req := writeRequest("DELETE", "/up/a.txt", "", false)
+	res := &Response{Header: make(Header), Request: req}
+	target := f.prepareWrite(req, res, r.Root)
+	if target == "" {
+		t.Fatalf("target got none, status %v", res.StatusCode)
+	}
+
+	// Replace the directory resolved with a link leading outside the root
+	up := filepath.Join(root, "up")
+	if err := os.RemoveAll(up); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../outside/up", up); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Remove(target); err == nil {
+		t.Fatal("file got removed through a link leading outside the root")
+	}
+	if _, statusCode := upload(writeRequest("PUT", "/up/b.txt", "b", false), r.Root, filepath.Dir(target), 8); statusCode == statusOK {
+		t.Fatal("file got written through a link leading outside the root")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside/up/a.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,306 @@
+package gohttp
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// bufferSize is how many body bytes a response holds back before
+// sending its headers. If a handler writes no more than this in total,
+// the "Content-Length" header is set automatically.
+const bufferSize = 4096
+
+var (
+	// ErrBodyNotAllowed is returned by ResponseWriter.Write calls
+	// when the response status code does not permit a body.
+	ErrBodyNotAllowed = errors.New("gohttp: response status code does not allow body")
+
+	// ErrContentLength is returned by ResponseWriter.Write calls
+	// when a handler writes more than the declared "Content-Length".
+	ErrContentLength = errors.New("gohttp: wrote more than the declared Content-Length")
+)
+
+// A ResponseWriter is used by a Handler to construct the response
+// to a request.
+type ResponseWriter interface {
+	// Header returns the header map that will be sent with the response.
+	// Changing the header after the first call to Write has no effect.
+	Header() Header
+
+	// WriteHeader sets the status code of the response.
+	// If it is not called explicitly, the first call to Write
+	// (or the handler returning) implies WriteHeader(200).
+	//
+	// A 1xx status code, such as 103 Early Hints, sends an interim
+	// response with the header as it is, and WriteHeader can then be
+	// called again with the final status code.
+	WriteHeader(statusCode int)
+
+	// Write writes p as part of the response body.
+	Write(p []byte) (int, error)
+}
+
+// The Flusher interface is implemented by ResponseWriters that allow
+// a handler to send what it has written so far to the client.
+//
+// Flushing before the handler returns sends the headers right away,
+// so a body of unknown length is then sent with the chunked transfer
+// coding.
+type Flusher interface {
+	Flush()
+}
+
+// response is the ResponseWriter the server passes to handlers.
+// It holds back the headers until the handler has either returned,
+// flushed, or written more than bufferSize bytes, so that the
+// "Content-Length" header can be filled in for small generated bodies.
+// Otherwise, the body is sent with "Transfer-Encoding: chunked".
+type response struct {
+	bw     *bufio.Writer
+	req    *Request
+	header Header
+
+	status      int
+	wroteHeader bool // WriteHeader has been called
+	sentHeader  bool // the status line and headers are written to bw
+	buf         []byte
+
+	contentLength int64 // declared "Content-Length", or -1 if unknown
+	chunked       bool  // the body is sent with the chunked transfer coding
+	written       int64 // body bytes written to bw
+	closeAfter    bool  // the connection must be closed after this response
+
+	expectContinue bool // the client waits for "100 Continue" to send the body
+	wroteContinue  bool // "100 Continue" has been sent
+}
+
+func newResponse(bw *bufio.Writer, req *Request) *response {
+	return &response{
+		bw:            bw,
+		req:           req,
+		header:        make(Header),
+		contentLength: -1,
+	}
+}
+
+func (w *response) Header() Header {
+	return w.header
+}
+
+func (w *response) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	if isInterim(statusCode) {
+		if err := w.writeInterim(statusCode); err != nil {
+			w.req.logger().Debug("failed to write interim response", "status", statusCode, "err", err)
+		}
+		return
+	}
+	w.wroteHeader = true
+	w.status = statusCode
+}
+
+// writeInterim sends an interim response with the 1xx statusCode,
+// and the header as it is for any other than 100 Continue.
+// HTTP/1.0 clients don't get any (RFC 9110, Section 15.2).
+func (w *response) writeInterim(statusCode int) error {
+	if w.req.http10() {
+		return nil
+	}
+	if statusCode == statusContinue {
+		return w.writeContinue()
+	}
+	res := &Response{
+		StatusCode: statusCode,
+		Proto:      responseProto,
+		Header:     w.header,
+	}
+	if err := res.WriteStatusLine(w.bw); err != nil {
+		return err
+	}
+	if err := res.WriteSortedHeaders(w.bw); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+// writeContinue sends "100 Continue", unless it is sent already or
+// it is too late for it, once the final status line is sent.
+func (w *response) writeContinue() error {
+	if w.wroteContinue || w.sentHeader {
+		return nil
+	}
+	w.wroteContinue = true
+	if _, err := w.bw.WriteString(responseProto + " 100 Continue\r\n\r\n"); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+func (w *response) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(statusOK)
+	}
+	if !bodyAllowed(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	if !w.sentHeader {
+		if len(w.buf)+len(p) <= bufferSize {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		}
+		if err := w.sendHeader(); err != nil {
+			return 0, err
+		}
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.writeBody(buf); err != nil {
+			return 0, err
+		}
+	}
+	return w.writeBody(p)
+}
+
+func (w *response) writeBody(p []byte) (int, error) {
+	if w.contentLength >= 0 && w.written+int64(len(p)) > w.contentLength {
+		return 0, ErrContentLength
+	}
+	if w.req.Method == "HEAD" {
+		// Same headers as GET, but the body is never sent
+		w.written += int64(len(p))
+		return len(p), nil
+	}
+	var n int
+	var err error
+	if w.chunked {
+		n, err = (&chunkedWriter{w: w.bw}).Write(p)
+	} else {
+		n, err = w.bw.Write(p)
+	}
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush sends the headers, if not sent yet, and any buffered body
+// to the client.
+func (w *response) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(statusOK)
+	}
+	if !w.sentHeader {
+		if err := w.sendHeader(); err != nil {
+			return
+		}
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.writeBody(buf); err != nil {
+			return
+		}
+	}
+	w.bw.Flush()
+}
+
+// sendHeader writes the status line and headers of w to the connection.
+func (w *response) sendHeader() error {
+	w.sentHeader = true
+	if !w.header.has("Date") {
+		w.header.Set("Date", FormatTime(time.Now()))
+	}
+	// Without "100 Continue", the client may or may not send the body
+	// it was about to, so the next request can't be found after it
+	if w.req.Close || (w.expectContinue && !w.wroteContinue) {
+		w.header.Set("Connection", "close")
+	} else if w.req.http10() && !w.header.has("Connection") {
+		// HTTP/1.0 clients close the connection unless told otherwise
+		w.header.Set("Connection", "keep-alive")
+	}
+	if w.header.has("Content-Length") {
+		n, err := strconv.ParseInt(w.header.Get("Content-Length"), 10, 64)
+		if err != nil || n < 0 {
+			w.header.Del("Content-Length")
+		} else {
+			w.contentLength = n
+		}
+	}
+	w.header.Del("Transfer-Encoding")
+	if w.status == statusNotModified {
+		// A 304 only carries the metadata of the response it stands for
+		// that caches need (RFC 9110, Section 15.4.5)
+		w.header.Del("Content-Type")
+	}
+	// A response to HEAD has no body to frame
+	if w.contentLength < 0 && bodyAllowed(w.status) && w.req.Method != "HEAD" {
+		if w.req.http10() {
+			// HTTP/1.0 has no chunked transfer coding, so the end of
+			// the connection marks the end of the body
+			w.header.Set("Connection", "close")
+		} else {
+			// Without a length, each piece of the body is sent
+			// as a chunk prefixed with its size.
+			w.header.Set("Transfer-Encoding", "chunked")
+			w.chunked = true
+		}
+	}
+	if w.header.Get("Connection") == "close" {
+		w.closeAfter = true
+	}
+
+	res := &Response{
+		StatusCode: w.status,
+		Proto:      responseProto,
+		Header:     w.header,
+		Request:    w.req,
+	}
+	if err := res.WriteStatusLine(w.bw); err != nil {
+		return err
+	}
+	return res.WriteSortedHeaders(w.bw)
+}
+
+// finish completes the response after the handler returns,
+// and flushes everything to the connection.
+func (w *response) finish() error {
+	if !w.wroteHeader {
+		w.WriteHeader(statusOK)
+	}
+	if !w.sentHeader {
+		if !w.header.has("Content-Length") && bodyAllowed(w.status) {
+			w.header.Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+		if err := w.sendHeader(); err != nil {
+			return err
+		}
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.writeBody(buf); err != nil {
+			return err
+		}
+	}
+	if w.chunked && w.req.Method != "HEAD" {
+		if err := (&chunkedWriter{w: w.bw}).Close(); err != nil {
+			return err
+		}
+	}
+	if w.contentLength >= 0 && w.written < w.contentLength && w.req.Method != "HEAD" {
+		// The client is still waiting for the rest of the body,
+		// so the connection can't be reused.
+		w.closeAfter = true
+	}
+	return w.bw.Flush()
+}
+
+// isInterim reports whether statusCode is that of an interim response,
+// which precedes the final response to a request. 101 Switching
+// Protocols is not, as it ends HTTP on the connection.
+func isInterim(statusCode int) bool {
+	return statusCode >= 100 && statusCode < 200 && statusCode != 101
+}
+
+// bodyAllowed reports whether a response with the given status code
+// may include a body.
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != 204 && statusCode != 304
+}
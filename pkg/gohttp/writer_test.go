@@ -0,0 +1,197 @@
+package gohttp
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var tests = []struct {
+		name          string
+		handler       HandlerFunc
+		headersWant   map[string]string
+		bodyWant      string
+		closeConnWant bool
+	}{
+		{
+			"Buffered",
+			func(w ResponseWriter, req *Request) {
+				w.Write([]byte("hello, "))
+				w.Write([]byte("world"))
+			},
+			map[string]string{
+				"Content-Length": "12",
+			},
+			"hello, world",
+			false,
+		},
+		{
+			"Empty",
+			func(w ResponseWriter, req *Request) {
+				w.WriteHeader(204)
+			},
+			map[string]string{},
+			"",
+			false,
+		},
+		{
+			"DeclaredLength",
+			func(w ResponseWriter, req *Request) {
+				w.Header().Set("Content-Length", "5000")
+				w.Write([]byte(strings.Repeat("a", 5000)))
+			},
+			map[string]string{
+				"Content-Length": "5000",
+			},
+			strings.Repeat("a", 5000),
+			false,
+		},
+		{
+			"UnknownLength",
+			func(w ResponseWriter, req *Request) {
+				w.Write([]byte(strings.Repeat("a", 3000)))
+				w.Write([]byte(strings.Repeat("b", 3000)))
+			},
+			map[string]string{
+				"Transfer-Encoding": "chunked",
+			},
+			"bb8\r\n" + strings.Repeat("a", 3000) + "\r\n" +
+				"bb8\r\n" + strings.Repeat("b", 3000) + "\r\n" +
+				"0\r\n\r\n",
+			false,
+		},
+		{
+			"Flush",
+			func(w ResponseWriter, req *Request) {
+				w.Write([]byte("hello, "))
+				w.(Flusher).Flush()
+				w.Write([]byte("world"))
+			},
+			map[string]string{
+				"Transfer-Encoding": "chunked",
+			},
+			"7\r\nhello, \r\n" +
+				"5\r\nworld\r\n" +
+				"0\r\n\r\n",
+			false,
+		},
+		{
+			"FlushDeclaredLength",
+			func(w ResponseWriter, req *Request) {
+				w.Header().Set("Content-Length", "12")
+				w.Write([]byte("hello, "))
+				w.(Flusher).Flush()
+				w.Write([]byte("world"))
+			},
+			map[string]string{
+				"Content-Length": "12",
+			},
+			"hello, world",
+			false,
+		},
+		{
+			"ShortBody",
+			func(w ResponseWriter, req *Request) {
+				w.Header().Set("Content-Length", "10")
+				w.Write([]byte("short"))
+			},
+			map[string]string{
+				"Content-Length": "10",
+			},
+			"short",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{}}
+			w := newResponse(bufio.NewWriter(&buffer), req)
+			tt.handler(w, req)
+			if err := w.finish(); err != nil {
+				t.Fatal(err)
+			}
+			if w.closeAfter != tt.closeConnWant {
+				t.Fatalf("close connection got: %v, want: %v", w.closeAfter, tt.closeConnWant)
+			}
+
+			resText := buffer.String()
+			i := strings.Index(resText, "\r\n\r\n")
+			if i < 0 {
+				t.Fatalf("no end of headers found in %q", resText)
+			}
+			headerText, body := resText[:i+2], resText[i+4:]
+			for h, vWant := range tt.headersWant {
+				if !strings.Contains(headerText, "\r\n"+h+": "+vWant+"\r\n") {
+					t.Fatalf("missing header %q: %q in %q", h, vWant, headerText)
+				}
+			}
+			if body != tt.bodyWant {
+				if len(tt.bodyWant) <= 128 {
+					t.Fatalf("\nbody got: %q\nwant: %q", body, tt.bodyWant)
+				}
+				t.Fatalf("body got: %v bytes, want: %v bytes", len(body), len(tt.bodyWant))
+			}
+		})
+	}
+}
+
+func TestResponseWriterContentLength(t *testing.T) {
+	var buffer bytes.Buffer
+	req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{}}
+	w := newResponse(bufio.NewWriter(&buffer), req)
+	w.Header().Set("Content-Length", "1")
+	if _, err := w.Write([]byte(strings.Repeat("a", 5000))); err != ErrContentLength {
+		t.Fatalf("got error: %v, want: %v", err, ErrContentLength)
+	}
+}
+
+func TestResponseWriterFlushReachesClient(t *testing.T) {
+	var buffer bytes.Buffer
+	req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: "HTTP/1.1", Header: Header{}}
+	w := newResponse(bufio.NewWriter(&buffer), req)
+	w.Write([]byte("partial"))
+	if buffer.Len() != 0 {
+		t.Fatalf("got %q before flushing, want nothing", buffer.String())
+	}
+	w.Flush()
+	if !strings.HasSuffix(buffer.String(), "\r\n\r\n7\r\npartial\r\n") {
+		t.Fatalf("got %q after flushing, want the partial body", buffer.String())
+	}
+}
+
+func TestResponseWriterInterim(t *testing.T) {
+	var tests = []struct {
+		name   string
+		proto  string
+		prefix string
+	}{
+		{"EarlyHints", "HTTP/1.1", "HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\nHTTP/1.1 200 OK\r\n"},
+		{"NoneForHTTP10", "HTTP/1.0", "HTTP/1.1 200 OK\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			req := &Request{Method: "GET", URL: mustParseURL("/"), Proto: tt.proto, Header: Header{}}
+			w := newResponse(bufio.NewWriter(&buffer), req)
+			w.Header().Set("Link", "</style.css>; rel=preload")
+			w.WriteHeader(103)
+			w.Header().Del("Link")
+			w.WriteHeader(200)
+			w.Write([]byte("hello"))
+			if err := w.finish(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buffer.String(); !strings.HasPrefix(got, tt.prefix) {
+				t.Fatalf("\ngot: %q\nwant prefix: %q", got, tt.prefix)
+			}
+			if strings.Count(buffer.String(), "Link: ") > 1 {
+				t.Fatalf("got %q, want the link in the interim response only", buffer.String())
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ var statusLineWant = map[int]string{
 	200: "HTTP/1.1 200 OK",
 	400: "HTTP/1.1 400 Bad Request",
 	404: "HTTP/1.1 404 Not Found",
+	501: "HTTP/1.1 501 Not Implemented",
 }
 
 func (rc *ResponseChecker) Check(br *bufio.Reader) error {
@@ -54,18 +55,22 @@ func (rc *ResponseChecker) Check(br *bufio.Reader) error {
 			{"Content-Length", fmt.Sprint(fi.Size())},
 			{"Content-Type", rc.ContentType},
 			{"Date", ""},
+			{"Etag", ""},
 			{"Last-Modified", ""},
 		}
 		if rc.Close {
 			specs = append(connCloseHeader, specs...)
 		}
-	case 400:
+		specs = append([]HeaderSpec{{"Accept-Ranges", "bytes"}}, specs...)
+	case 400, 501:
 		specs = []HeaderSpec{
 			{"Connection", "close"},
+			{"Content-Length", "0"},
 			{"Date", ""},
 		}
 	case 404:
 		specs = []HeaderSpec{
+			{"Content-Length", "0"},
 			{"Date", ""},
 		}
 		if rc.Close {
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -44,8 +45,13 @@ func TestMain(m *testing.M) {
 	// Start running the test cases
 	code := m.Run()
 
-	// Kill the test server process
-	serverCmd.Process.Kill()
+	// Shut down the test server process
+	if err := serverCmd.Process.Signal(syscall.SIGTERM); err != nil {
+		log.Print(err)
+	}
+	if err := serverCmd.Wait(); err != nil {
+		log.Printf("Test server exited with an error: %v", err)
+	}
 
 	os.Exit(code)
 }
@@ -171,7 +177,8 @@ func TestPipelineRequest(t *testing.T) {
 					Close:       false,
 				},
 				{
-					StatusCode: 400,
+					// "GETT" is well-formed, but not a method we know
+					StatusCode: 501,
 				},
 			},
 		},